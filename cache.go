@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"context"
+	"iter"
 	"time"
 )
 
@@ -16,3 +18,90 @@ type Cache interface {
 	//input : output:error
 	Clear() error
 }
+
+// ContextCache is the context-aware variant of Cache.
+// A cancelled or expired ctx makes the call return ctx.Err() (possibly wrapped)
+// instead of touching the backend.
+type ContextCache interface {
+	SetCtx(ctx context.Context, key string, value interface{}) error
+	SetWithTTLCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	GetCtx(ctx context.Context, key string) (interface{}, error)
+	DeleteCtx(ctx context.Context, key string) error
+	ClearCtx(ctx context.Context) error
+}
+
+// BatchCache reads and writes many keys in a single call, so remote backends
+// need one round-trip instead of one per key.
+// an empty key anywhere in the batch returns ErrEmptyKey before anything is done.
+type BatchCache interface {
+	// GetMulti returns the keys that exist, missing and expired keys are left out.
+	GetMulti(keys []string) (map[string]interface{}, error)
+	// SetMulti adds or updates every item with the same ttl, 0 means no expiry.
+	SetMulti(items map[string]interface{}, ttl time.Duration) error
+	// DeleteMulti removes the keys, keys that do not exist are ignored.
+	DeleteMulti(keys []string) error
+}
+
+// TagCache groups keys under tags so they can be dropped together.
+// InvalidateTag removes every key whose latest write carried the tag, keys
+// that only an earlier write tagged may be removed as well.
+type TagCache interface {
+	// SetWithTags is SetWithTTL (0 means no expiry) that also files key
+	// under every tag. an empty tag returns ErrEmptyTag.
+	SetWithTags(key string, value interface{}, ttl time.Duration, tags ...string) error
+	// InvalidateTag removes the keys tagged with tag, an unknown tag is not an error.
+	InvalidateTag(tag string) error
+}
+
+// CounterCache adds to integer values atomically, so concurrent updates are
+// not lost. a missing key counts from 0, a value that is not an integer
+// returns ErrNotInteger.
+type CounterCache interface {
+	// Incr adds delta to key and returns the new value.
+	Incr(key string, delta int64) (int64, error)
+	// Decr subtracts delta from key and returns the new value.
+	Decr(key string, delta int64) (int64, error)
+	// IncrWithTTL is Incr that gives a key it creates the ttl, 0 means no
+	// expiry. an existing key keeps its expiry.
+	IncrWithTTL(key string, delta int64, ttl time.Duration) (int64, error)
+	// DecrWithTTL is Decr with the ttl of IncrWithTTL.
+	DecrWithTTL(key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+// ConditionalCache writes only when the key is in the expected state, so
+// concurrent writers cannot overwrite each other unnoticed. a ttl of 0
+// means no expiry, the new value never keeps the old one's ttl.
+type ConditionalCache interface {
+	// Add stores value only if key is missing, ErrKeyExists otherwise.
+	Add(key string, value interface{}, ttl time.Duration) error
+	// Replace stores value only if key exists, ErrKeyNotFound otherwise.
+	Replace(key string, value interface{}, ttl time.Duration) error
+	// CompareAndSwap stores new only if key still holds old and reports
+	// whether it did. a missing key returns ErrKeyNotFound.
+	CompareAndSwap(key string, old, new interface{}, ttl time.Duration) (bool, error)
+}
+
+// IterableCache lists what a cache holds. keys come in no particular order.
+type IterableCache interface {
+	// Keys yields the live keys starting with prefix, "" yields all of them.
+	Keys(prefix string) iter.Seq[string]
+	// Range yields the live keys starting with prefix with their values.
+	Range(prefix string) iter.Seq2[string, interface{}]
+}
+
+// Iterable returns c, or the first cache it wraps through Unwrap, as an
+// IterableCache. backends that cannot list their keys, like memcached,
+// return ErrNotSupported.
+func Iterable(c Cache) (IterableCache, error) {
+	for c != nil {
+		if ic, ok := c.(IterableCache); ok {
+			return ic, nil
+		}
+		u, ok := c.(interface{ Unwrap() Cache })
+		if !ok {
+			break
+		}
+		c = u.Unwrap()
+	}
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,137 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Codec turns cache values into bytes for the remote backends and back.
+type Codec interface {
+	// Name is the identifier used by ByName and factory.Config.Codec.
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes data into v, which must be a non-nil pointer.
+	// decoding into *interface{} yields the codec's natural representation.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// the value has a type the codec cannot encode
+	ErrUnsupportedValue = errors.New("codec: unsupported value type")
+	// no codec is registered under the requested name
+	ErrUnknownCodec = errors.New("codec: unknown codec")
+)
+
+// codecs selectable by name
+var (
+	JSON    Codec = jsonCodec{}
+	Gob     Codec = gobCodec{}
+	Msgpack Codec = msgpackCodec{}
+	Raw     Codec = rawCodec{}
+)
+
+// ByName returns the codec registered under name. an empty name is JSON,
+// the default of every backend.
+func ByName(name string) (Codec, error) {
+	switch name {
+	case "", JSON.Name():
+		return JSON, nil
+	case Gob.Name():
+		return Gob, nil
+	case Msgpack.Name():
+		return Msgpack, nil
+	case Raw.Name():
+		return Raw, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+}
+
+// json: readable on the server, but structs come back as
+// map[string]interface{} and numbers as float64 when decoded into interface{}.
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// gob: values are sent as interfaces so the concrete type survives the round
+// trip. types other than the builtin ones must be registered with gob.Register
+// in every process that reads them.
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	var out interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&out); err != nil {
+		return err
+	}
+	return setInto(v, out)
+}
+
+// raw: []byte and string values are stored untouched, anything else is
+// rejected. values decode as []byte unless a *string is given.
+type rawCodec struct{}
+
+func (rawCodec) Name() string { return "raw" }
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch b := v.(type) {
+	case []byte:
+		return b, nil
+	case string:
+		return []byte(b), nil
+	}
+	return nil, fmt.Errorf("%w: raw codec takes []byte or string, got %T", ErrUnsupportedValue, v)
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch p := v.(type) {
+	case *[]byte:
+		*p = append([]byte(nil), data...)
+	case *string:
+		*p = string(data)
+	case *interface{}:
+		*p = append([]byte(nil), data...)
+	default:
+		return fmt.Errorf("%w: raw codec cannot decode into %T", ErrUnsupportedValue, v)
+	}
+	return nil
+}
+
+// setInto stores a decoded value in the pointer v.
+func setInto(v interface{}, val interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("codec: cannot decode into non-pointer %T", v)
+	}
+	elem := rv.Elem()
+	if val == nil {
+		elem.Set(reflect.Zero(elem.Type()))
+		return nil
+	}
+	src := reflect.ValueOf(val)
+	switch {
+	case src.Type().AssignableTo(elem.Type()):
+		elem.Set(src)
+	case src.Type().ConvertibleTo(elem.Type()) && src.Kind() == elem.Kind():
+		elem.Set(src.Convert(elem.Type()))
+	default:
+		return fmt.Errorf("codec: cannot decode %T into %s", val, elem.Type())
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sample struct {
+	Name    string
+	Count   int
+	Ratio   float64
+	Tags    []string
+	Attrs   map[string]int
+	Payload []byte
+	Nested  *sample
+	Skipped string `msgpack:"-"`
+	Renamed bool   `msgpack:"flag"`
+}
+
+func init() {
+	gob.Register(sample{})
+}
+
+func TestByName(t *testing.T) {
+	for _, name := range []string{"", "json", "gob", "msgpack", "raw"} {
+		c, err := ByName(name)
+		if err != nil {
+			t.Fatalf("ByName(%q) failed: %v", name, err)
+		}
+		if name != "" && c.Name() != name {
+			t.Errorf("Expected codec %q, got %q", name, c.Name())
+		}
+	}
+	if _, err := ByName("xml"); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("Expected ErrUnknownCodec, got %v", err)
+	}
+}
+
+// every codec that takes arbitrary values must round-trip typed structs
+func TestTypedRoundTrip(t *testing.T) {
+	in := sample{
+		Name:    "ana",
+		Count:   -42,
+		Ratio:   0.25,
+		Tags:    []string{"a", "b"},
+		Attrs:   map[string]int{"x": 1, "y": 300},
+		Payload: []byte{0, 1, 2},
+		Nested:  &sample{Name: "child"},
+		Renamed: true,
+	}
+	for _, c := range []Codec{JSON, Gob, Msgpack} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := c.Marshal(in)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			var out sample
+			if err := c.Unmarshal(data, &out); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			want := in
+			if c == Msgpack {
+				// dropped by the tag
+				want.Skipped = ""
+			}
+			if !reflect.DeepEqual(out, want) {
+				t.Errorf("Expected %+v, got %+v", want, out)
+			}
+		})
+	}
+}
+
+// gob keeps the concrete type when decoding into interface{}
+func TestGobKeepsConcreteType(t *testing.T) {
+	data, err := Gob.Marshal(sample{Name: "x"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var out interface{}
+	if err := Gob.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if s, ok := out.(sample); !ok || s.Name != "x" {
+		t.Errorf("Expected sample{Name: x}, got %#v", out)
+	}
+}
+
+func TestMsgpackGeneric(t *testing.T) {
+	cases := []struct {
+		in   interface{}
+		want interface{}
+	}{
+		{nil, nil},
+		{true, true},
+		{5, int64(5)},
+		{-3, int64(-3)},
+		{-200, int64(-200)},
+		{int64(math.MaxInt64), int64(math.MaxInt64)},
+		{uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{1.5, 1.5},
+		{"hi", "hi"},
+		{strings.Repeat("s", 300), strings.Repeat("s", 300)},
+		{[]byte("raw"), []byte("raw")},
+		{[]int{1, 2}, []interface{}{int64(1), int64(2)}},
+		{map[string]string{"k": "v"}, map[string]interface{}{"k": "v"}},
+		{map[int]bool{1: true}, map[interface{}]interface{}{int64(1): true}},
+	}
+	for _, tc := range cases {
+		data, err := Msgpack.Marshal(tc.in)
+		if err != nil {
+			t.Fatalf("Marshal(%v) failed: %v", tc.in, err)
+		}
+		var out interface{}
+		if err := Msgpack.Unmarshal(data, &out); err != nil {
+			t.Fatalf("Unmarshal(%v) failed: %v", tc.in, err)
+		}
+		if !reflect.DeepEqual(out, tc.want) {
+			t.Errorf("Expected %#v, got %#v", tc.want, out)
+		}
+	}
+}
+
+func TestMsgpackMalformed(t *testing.T) {
+	var out interface{}
+	if err := Msgpack.Unmarshal([]byte{0xa5, 'a'}, &out); err == nil {
+		t.Error("Expected error for truncated string")
+	}
+	if err := Msgpack.Unmarshal([]byte{0x01, 0x02}, &out); err == nil {
+		t.Error("Expected error for trailing bytes")
+	}
+	if _, err := Msgpack.Marshal(make(chan int)); !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("Expected ErrUnsupportedValue, got %v", err)
+	}
+}
+
+// values that encode themselves keep their state, other opaque structs fail
+func TestMsgpackMarshalers(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	data, err := Msgpack.Marshal(now)
+	if err != nil {
+		t.Fatalf("Marshal(time) failed: %v", err)
+	}
+	var got time.Time
+	if err := Msgpack.Unmarshal(data, &got); err != nil || !got.Equal(now) {
+		t.Errorf("Expected %v, got %v, %v", now, got, err)
+	}
+	var generic interface{}
+	Msgpack.Unmarshal(data, &generic)
+	if generic != now.Format(time.RFC3339Nano) {
+		t.Errorf("Expected the text form, got %#v", generic)
+	}
+
+	type opaque struct{ n int }
+	if _, err := Msgpack.Marshal(opaque{1}); !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("Expected ErrUnsupportedValue for unexported fields, got %v", err)
+	}
+	if _, err := Msgpack.Marshal(struct{}{}); err != nil {
+		t.Errorf("Expected an empty struct to encode, got %v", err)
+	}
+}
+
+// self references and deep nesting fail instead of overflowing the stack
+func TestMsgpackDepth(t *testing.T) {
+	loop := &sample{Name: "loop"}
+	loop.Nested = loop
+	if _, err := Msgpack.Marshal(loop); err == nil {
+		t.Error("Expected error for a self-referencing value")
+	}
+	m := map[string]interface{}{}
+	m["self"] = m
+	if _, err := Msgpack.Marshal(m); err == nil {
+		t.Error("Expected error for a map containing itself")
+	}
+	var out interface{}
+	deep := bytes.Repeat([]byte{0x91}, 100000)
+	if err := Msgpack.Unmarshal(append(deep, 0xc0), &out); err == nil {
+		t.Error("Expected error for deeply nested data")
+	}
+}
+
+func TestRaw(t *testing.T) {
+	data, err := Raw.Marshal("abc")
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var out interface{}
+	if err := Raw.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(out, []byte("abc")) {
+		t.Errorf("Expected []byte(abc), got %#v", out)
+	}
+	var s string
+	if err := Raw.Unmarshal(data, &s); err != nil || s != "abc" {
+		t.Errorf("Expected 'abc', got %q (%v)", s, err)
+	}
+	if _, err := Raw.Marshal(12); !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("Expected ErrUnsupportedValue, got %v", err)
+	}
+}
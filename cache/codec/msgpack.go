@@ -0,0 +1,614 @@
+package codec
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// msgpack is a compact binary codec speaking the commonly used subset of
+// MessagePack: nil, bool, integers, floats, str, bin, array and map.
+// structs are written as maps keyed by field name (or the `msgpack` tag,
+// "-" skips a field). values implementing encoding.TextMarshaler are
+// written as str, encoding.BinaryMarshaler as bin, and read back through
+// the matching Unmarshaler; other structs without exported fields are
+// rejected. extension types are not supported.
+//
+// decoding into *interface{} gives nil, bool, int64, uint64 (only above
+// MaxInt64), float64, string, []byte, []interface{} and
+// map[string]interface{}; decoding into a typed pointer fills it in.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf []byte
+	return encodeValue(buf, reflect.ValueOf(v), 0)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("codec: cannot decode into non-pointer %T", v)
+	}
+	d := decoder{data: data}
+	val, err := d.value()
+	if err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return errMsgpackTrailing
+	}
+	return assign(rv.Elem(), val)
+}
+
+var (
+	errMsgpackShort    = errors.New("codec: msgpack data is truncated")
+	errMsgpackTrailing = errors.New("codec: trailing bytes after msgpack value")
+	errMsgpackDepth    = errors.New("codec: msgpack value nested too deep")
+)
+
+// maxDepth bounds the nesting of encoded and decoded values, so a value
+// that refers to itself fails instead of overflowing the stack.
+const maxDepth = 1000
+
+func encodeValue(buf []byte, rv reflect.Value, depth int) ([]byte, error) {
+	if !rv.IsValid() {
+		return append(buf, 0xc0), nil
+	}
+	if depth > maxDepth {
+		return nil, errMsgpackDepth
+	}
+	if out, ok, err := encodeMarshaler(buf, rv); ok {
+		return out, err
+	}
+	depth++
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return encodeInt(buf, rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			buf = append(buf, 0xcf)
+			return binary.BigEndian.AppendUint64(buf, u), nil
+		}
+		return encodeInt(buf, int64(u)), nil
+	case reflect.Float32, reflect.Float64:
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(rv.Float())), nil
+	case reflect.String:
+		return encodeStr(buf, rv.String()), nil
+	case reflect.Interface, reflect.Pointer:
+		if rv.IsNil() {
+			return append(buf, 0xc0), nil
+		}
+		return encodeValue(buf, rv.Elem(), depth)
+	case reflect.Slice:
+		if rv.IsNil() {
+			return append(buf, 0xc0), nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return encodeBin(buf, rv.Bytes()), nil
+		}
+		fallthrough
+	case reflect.Array:
+		buf = encodeLen(buf, rv.Len(), 0x90, 0xdc, 0xdd)
+		var err error
+		for i := 0; i < rv.Len(); i++ {
+			if buf, err = encodeValue(buf, rv.Index(i), depth); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return append(buf, 0xc0), nil
+		}
+		buf = encodeLen(buf, rv.Len(), 0x80, 0xde, 0xdf)
+		var err error
+		iter := rv.MapRange()
+		for iter.Next() {
+			if buf, err = encodeValue(buf, iter.Key(), depth); err != nil {
+				return nil, err
+			}
+			if buf, err = encodeValue(buf, iter.Value(), depth); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Struct:
+		fields := structFields(rv.Type())
+		if len(fields) == 0 && rv.NumField() > 0 {
+			// e.g. time.Time would silently come back as the zero value
+			return nil, fmt.Errorf("%w: msgpack cannot encode %s without exported fields", ErrUnsupportedValue, rv.Type())
+		}
+		buf = encodeLen(buf, len(fields), 0x80, 0xde, 0xdf)
+		var err error
+		for _, f := range fields {
+			buf = encodeStr(buf, f.name)
+			if buf, err = encodeValue(buf, rv.Field(f.index), depth); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("%w: msgpack cannot encode %s", ErrUnsupportedValue, rv.Type())
+}
+
+// encodeMarshaler writes values that encode themselves, ok reports whether
+// rv is one.
+func encodeMarshaler(buf []byte, rv reflect.Value) (out []byte, ok bool, err error) {
+	if rv.Kind() == reflect.Interface || (rv.Kind() == reflect.Pointer && rv.IsNil()) || !rv.CanInterface() {
+		return nil, false, nil
+	}
+	switch m := rv.Interface().(type) {
+	case encoding.TextMarshaler:
+		text, err := m.MarshalText()
+		if err != nil {
+			return nil, true, err
+		}
+		return encodeStr(buf, string(text)), true, nil
+	case encoding.BinaryMarshaler:
+		data, err := m.MarshalBinary()
+		if err != nil {
+			return nil, true, err
+		}
+		return encodeBin(buf, data), true, nil
+	}
+	return nil, false, nil
+}
+
+func encodeInt(buf []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		return append(buf, byte(i))
+	case i < 0 && i >= -32:
+		return append(buf, byte(int8(i)))
+	}
+	buf = append(buf, 0xd3)
+	return binary.BigEndian.AppendUint64(buf, uint64(i))
+}
+
+func encodeStr(buf []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = append(buf, 0xda)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 0xdb)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(n))
+	}
+	return append(buf, s...)
+}
+
+func encodeBin(buf []byte, b []byte) []byte {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		buf = append(buf, 0xc5)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 0xc6)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(n))
+	}
+	return append(buf, b...)
+}
+
+// encodeLen writes an array or map header: the fix form for up to 15
+// entries, then the 16 and 32 bit forms.
+func encodeLen(buf []byte, n int, fix, b16, b32 byte) []byte {
+	switch {
+	case n < 16:
+		return append(buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		buf = append(buf, b16)
+		return binary.BigEndian.AppendUint16(buf, uint16(n))
+	}
+	buf = append(buf, b32)
+	return binary.BigEndian.AppendUint32(buf, uint32(n))
+}
+
+type field struct {
+	name  string
+	index int
+}
+
+func structFields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("msgpack"); ok {
+			tag, _, _ = strings.Cut(tag, ",")
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, field{name: name, index: i})
+	}
+	return fields
+}
+
+type decoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, errMsgpackShort
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// value decodes the next value into its generic representation.
+func (d *decoder) value() (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	tag := b[0]
+	switch {
+	case tag <= 0x7f:
+		return int64(tag), nil
+	case tag >= 0xe0:
+		return int64(int8(tag)), nil
+	case tag&0xe0 == 0xa0:
+		return d.str(int(tag & 0x1f))
+	case tag&0xf0 == 0x90:
+		return d.array(int(tag & 0x0f))
+	case tag&0xf0 == 0x80:
+		return d.mapping(int(tag & 0x0f))
+	}
+	switch tag {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (tag - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 0xca:
+		u, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce:
+		u, err := d.uint(1 << (tag - 0xcc))
+		return int64(u), err
+	case 0xcf:
+		u, err := d.uint(8)
+		if u > math.MaxInt64 {
+			return u, err
+		}
+		return int64(u), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (tag - 0xd0)
+		u, err := d.uint(size)
+		// sign-extend from the encoded width
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (tag - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (tag - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (tag - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapping(int(n))
+	}
+	return nil, fmt.Errorf("%w: msgpack type 0x%02x", ErrUnsupportedValue, tag)
+}
+
+func (d *decoder) str(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *decoder) array(n int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errMsgpackShort
+	}
+	if d.depth++; d.depth > maxDepth {
+		return nil, errMsgpackDepth
+	}
+	defer func() { d.depth-- }()
+	out := make([]interface{}, n)
+	for i := range out {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// mapping returns map[string]interface{} when every key is a string, which
+// is what struct and JSON-like values produce, else map[interface{}]interface{}.
+func (d *decoder) mapping(n int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errMsgpackShort
+	}
+	if d.depth++; d.depth > maxDepth {
+		return nil, errMsgpackDepth
+	}
+	defer func() { d.depth-- }()
+	keys := make([]interface{}, n)
+	vals := make([]interface{}, n)
+	allStrings := true
+	for i := 0; i < n; i++ {
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := k.(string); !ok {
+			allStrings = false
+		}
+		keys[i], vals[i] = k, v
+	}
+	if allStrings {
+		out := make(map[string]interface{}, n)
+		for i, k := range keys {
+			out[k.(string)] = vals[i]
+		}
+		return out, nil
+	}
+	out := make(map[interface{}]interface{}, n)
+	for i, k := range keys {
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("%w: msgpack map key %T", ErrUnsupportedValue, k)
+		}
+		out[k] = vals[i]
+	}
+	return out, nil
+}
+
+// assign stores a generic decoded value into dst, converting it to dst's type.
+func assign(dst reflect.Value, val interface{}) error {
+	if val == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.Kind() != reflect.Pointer && dst.CanAddr() {
+		switch u := dst.Addr().Interface().(type) {
+		case encoding.TextUnmarshaler:
+			if text, ok := val.(string); ok {
+				return u.UnmarshalText([]byte(text))
+			}
+		case encoding.BinaryUnmarshaler:
+			if data, ok := val.([]byte); ok {
+				return u.UnmarshalBinary(data)
+			}
+		}
+	}
+	switch dst.Kind() {
+	case reflect.Interface:
+		src := reflect.ValueOf(val)
+		if !src.Type().AssignableTo(dst.Type()) {
+			return mismatch(val, dst)
+		}
+		dst.Set(src)
+		return nil
+	case reflect.Pointer:
+		p := reflect.New(dst.Type().Elem())
+		if err := assign(p.Elem(), val); err != nil {
+			return err
+		}
+		dst.Set(p)
+		return nil
+	case reflect.Bool:
+		b, ok := val.(bool)
+		if !ok {
+			return mismatch(val, dst)
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch n := val.(type) {
+		case int64:
+			i = n
+		case uint64:
+			return mismatch(val, dst)
+		case float64:
+			i = int64(n)
+		default:
+			return mismatch(val, dst)
+		}
+		if dst.OverflowInt(i) {
+			return mismatch(val, dst)
+		}
+		dst.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch n := val.(type) {
+		case int64:
+			if n < 0 {
+				return mismatch(val, dst)
+			}
+			u = uint64(n)
+		case uint64:
+			u = n
+		default:
+			return mismatch(val, dst)
+		}
+		if dst.OverflowUint(u) {
+			return mismatch(val, dst)
+		}
+		dst.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch n := val.(type) {
+		case float64:
+			dst.SetFloat(n)
+		case int64:
+			dst.SetFloat(float64(n))
+		default:
+			return mismatch(val, dst)
+		}
+		return nil
+	case reflect.String:
+		s, ok := val.(string)
+		if !ok {
+			return mismatch(val, dst)
+		}
+		dst.SetString(s)
+		return nil
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			switch b := val.(type) {
+			case []byte:
+				dst.SetBytes(append([]byte(nil), b...))
+				return nil
+			case string:
+				dst.SetBytes([]byte(b))
+				return nil
+			}
+		}
+		items, ok := val.([]interface{})
+		if !ok {
+			return mismatch(val, dst)
+		}
+		s := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := assign(s.Index(i), item); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+		return nil
+	case reflect.Array:
+		items, ok := val.([]interface{})
+		if !ok || len(items) != dst.Len() {
+			return mismatch(val, dst)
+		}
+		for i, item := range items {
+			if err := assign(dst.Index(i), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		m := reflect.MakeMap(dst.Type())
+		put := func(k, v interface{}) error {
+			kv := reflect.New(dst.Type().Key()).Elem()
+			if err := assign(kv, k); err != nil {
+				return err
+			}
+			vv := reflect.New(dst.Type().Elem()).Elem()
+			if err := assign(vv, v); err != nil {
+				return err
+			}
+			m.SetMapIndex(kv, vv)
+			return nil
+		}
+		switch src := val.(type) {
+		case map[string]interface{}:
+			for k, v := range src {
+				if err := put(k, v); err != nil {
+					return err
+				}
+			}
+		case map[interface{}]interface{}:
+			for k, v := range src {
+				if err := put(k, v); err != nil {
+					return err
+				}
+			}
+		default:
+			return mismatch(val, dst)
+		}
+		dst.Set(m)
+		return nil
+	case reflect.Struct:
+		src, ok := val.(map[string]interface{})
+		if !ok {
+			return mismatch(val, dst)
+		}
+		for _, f := range structFields(dst.Type()) {
+			v, ok := src[f.name]
+			if !ok {
+				continue
+			}
+			if err := assign(dst.Field(f.index), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return mismatch(val, dst)
+}
+
+func mismatch(val interface{}, dst reflect.Value) error {
+	return fmt.Errorf("codec: cannot decode msgpack %T into %s", val, dst.Type())
+}
@@ -2,11 +2,19 @@ package compliance
 
 import (
 	"Go-library/cache"
+	"Go-library/cache/cache/codec"
+	"context"
+	"errors"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 )
 
 // resuable test for the (set,get,delete,clear )
+// the optional subtests run for what cache.CapabilitiesOf reports.
+// a nil advanceTime sleeps, in-process backends should run on a
+// cache.FakeClock and pass its Advance.
 func RunTest(t *testing.T, setup func(t *testing.T) (cache.Cache, func(time.Duration))) {
 	t.Run("SetGet", func(t *testing.T) {
 		// setup is a function that returns a fresh instance of the Cache and a time advancer.
@@ -36,6 +44,122 @@ func RunTest(t *testing.T, setup func(t *testing.T) (cache.Cache, func(time.Dura
 		c, _ := setup(t)
 		testClear(t, c)
 	})
+	t.Run("Capabilities", func(t *testing.T) {
+		c, _ := setup(t)
+		testCapabilities(t, c)
+	})
+	t.Run("Values", func(t *testing.T) {
+		c, _ := setup(t)
+		testValues(t, c, cache.CapabilitiesOf(c).AnyValue)
+	})
+	t.Run("Context", func(t *testing.T) {
+		c, _ := setup(t)
+		if !cache.CapabilitiesOf(c).Context {
+			t.Skip("backend does not support cache.ContextCache")
+		}
+		testContext(t, c.(cache.ContextCache))
+	})
+	t.Run("Batch", func(t *testing.T) {
+		c, advanceTime := setup(t)
+		if !cache.CapabilitiesOf(c).Batch {
+			t.Skip("backend does not support cache.BatchCache")
+		}
+		if advanceTime == nil {
+			advanceTime = time.Sleep
+		}
+		testBatch(t, c, c.(cache.BatchCache), advanceTime)
+	})
+	t.Run("Stats", func(t *testing.T) {
+		c, _ := setup(t)
+		if !cache.CapabilitiesOf(c).Stats {
+			t.Skip("backend does not support cache.StatsProvider")
+		}
+		testStats(t, c, c.(cache.StatsProvider))
+	})
+	t.Run("Tags", func(t *testing.T) {
+		c, advanceTime := setup(t)
+		if !cache.CapabilitiesOf(c).Tags {
+			t.Skip("backend does not support cache.TagCache")
+		}
+		if advanceTime == nil {
+			advanceTime = time.Sleep
+		}
+		testTags(t, c, c.(cache.TagCache), advanceTime)
+	})
+	t.Run("Counters", func(t *testing.T) {
+		c, advanceTime := setup(t)
+		if !cache.CapabilitiesOf(c).Counters {
+			t.Skip("backend does not support cache.CounterCache")
+		}
+		if advanceTime == nil {
+			advanceTime = time.Sleep
+		}
+		testCounters(t, c, c.(cache.CounterCache), advanceTime)
+	})
+	t.Run("Conditional", func(t *testing.T) {
+		c, advanceTime := setup(t)
+		if !cache.CapabilitiesOf(c).Conditional {
+			t.Skip("backend does not support cache.ConditionalCache")
+		}
+		if advanceTime == nil {
+			advanceTime = time.Sleep
+		}
+		testConditional(t, c, c.(cache.ConditionalCache), advanceTime)
+	})
+	t.Run("Iteration", func(t *testing.T) {
+		c, _ := setup(t)
+		if !cache.CapabilitiesOf(c).Iteration {
+			t.Skip("backend does not support cache.IterableCache")
+		}
+		ic, err := cache.Iterable(c)
+		if err != nil {
+			t.Fatalf("Iteration is reported but Iterable failed: %v", err)
+		}
+		testIteration(t, c, ic)
+	})
+}
+
+// the descriptor must not promise an interface the cache lacks
+func testCapabilities(t *testing.T, c cache.Cache) {
+	caps := cache.CapabilitiesOf(c)
+	if _, ok := c.(cache.ContextCache); caps.Context && !ok {
+		t.Errorf("Context is reported but cache.ContextCache is not implemented")
+	}
+	if _, ok := c.(cache.BatchCache); caps.Batch && !ok {
+		t.Errorf("Batch is reported but cache.BatchCache is not implemented")
+	}
+	if _, ok := c.(cache.StatsProvider); caps.Stats && !ok {
+		t.Errorf("Stats is reported but cache.StatsProvider is not implemented")
+	}
+	if _, ok := c.(cache.TagCache); caps.Tags && !ok {
+		t.Errorf("Tags is reported but cache.TagCache is not implemented")
+	}
+	if _, ok := c.(cache.CounterCache); caps.Counters && !ok {
+		t.Errorf("Counters is reported but cache.CounterCache is not implemented")
+	}
+	if _, ok := c.(cache.ConditionalCache); caps.Conditional && !ok {
+		t.Errorf("Conditional is reported but cache.ConditionalCache is not implemented")
+	}
+	if _, err := cache.Iterable(c); caps.Iteration && err != nil {
+		t.Errorf("Iteration is reported but Iterable failed: %v", err)
+	}
+}
+
+// backends limited to some value types reject the rest on Set
+func testValues(t *testing.T, c cache.Cache, anyValue bool) {
+	err := c.Set("key-int", 42)
+	if anyValue {
+		if err != nil {
+			t.Fatalf("Set of an int failed: %v", err)
+		}
+		if _, err := c.Get("key-int"); err != nil {
+			t.Errorf("Get of an int failed: %v", err)
+		}
+		return
+	}
+	if !errors.Is(err, codec.ErrUnsupportedValue) {
+		t.Errorf("Expected codec.ErrUnsupportedValue for an int, got %v", err)
+	}
 }
 
 func testSetGet(t *testing.T, c cache.Cache) {
@@ -193,3 +317,369 @@ func testTTLOverwrite(t *testing.T, c cache.Cache, advanceTime func(time.Duratio
 		t.Errorf("Expected ErrKeyNotFound after extended expiration, got %v", err)
 	}
 }
+
+func testContext(t *testing.T, c cache.ContextCache) {
+	ctx := context.Background()
+
+	// a live context behaves like the plain api
+	if err := c.SetCtx(ctx, "key-ctx", "val"); err != nil {
+		t.Fatalf("SetCtx failed: %v", err)
+	}
+	val, err := c.GetCtx(ctx, "key-ctx")
+	if err != nil {
+		t.Fatalf("GetCtx failed: %v", err)
+	}
+	if val != "val" {
+		t.Errorf("Expected 'val', got %v", val)
+	}
+
+	// a cancelled context must be honoured by every method
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if err := c.SetCtx(cancelled, "key-ctx", "other"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for SetCtx, got %v", err)
+	}
+	if err := c.SetWithTTLCtx(cancelled, "key-ctx", "other", time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for SetWithTTLCtx, got %v", err)
+	}
+	if _, err := c.GetCtx(cancelled, "key-ctx"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for GetCtx, got %v", err)
+	}
+	if err := c.DeleteCtx(cancelled, "key-ctx"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for DeleteCtx, got %v", err)
+	}
+	if err := c.ClearCtx(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for ClearCtx, got %v", err)
+	}
+
+	// nothing above may have reached the backend
+	val, err = c.GetCtx(ctx, "key-ctx")
+	if err != nil {
+		t.Fatalf("Expected key to survive cancelled calls, got error: %v", err)
+	}
+	if val != "val" {
+		t.Errorf("Expected 'val' after cancelled writes, got %v", val)
+	}
+}
+
+func testBatch(t *testing.T, c cache.Cache, bc cache.BatchCache, advanceTime func(time.Duration)) {
+	items := map[string]interface{}{"b1": "v1", "b2": "v2", "b3": "v3"}
+	if err := bc.SetMulti(items, 0); err != nil {
+		t.Fatalf("SetMulti failed: %v", err)
+	}
+
+	// batch writes are visible to single reads
+	val, err := c.Get("b2")
+	if err != nil {
+		t.Fatalf("Get after SetMulti failed: %v", err)
+	}
+	if val != "v2" {
+		t.Errorf("Expected 'v2', got %v", val)
+	}
+
+	// missing keys are left out of the result
+	got, err := bc.GetMulti([]string{"b1", "b2", "b3", "missing"})
+	if err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("Expected 3 results, got %d: %v", len(got), got)
+	}
+	for k, want := range items {
+		if got[k] != want {
+			t.Errorf("Expected %s=%v, got %v", k, want, got[k])
+		}
+	}
+
+	// deleting a missing key in a batch is not an error
+	if err := bc.DeleteMulti([]string{"b1", "b2", "missing"}); err != nil {
+		t.Fatalf("DeleteMulti failed: %v", err)
+	}
+	got, err = bc.GetMulti([]string{"b1", "b2", "b3"})
+	if err != nil {
+		t.Fatalf("GetMulti after delete failed: %v", err)
+	}
+	if len(got) != 1 || got["b3"] != "v3" {
+		t.Errorf("Expected only b3 to remain, got %v", got)
+	}
+
+	// ttl applies to every item of the batch
+	if err := bc.SetMulti(map[string]interface{}{"t1": "x", "t2": "y"}, 1*time.Second); err != nil {
+		t.Fatalf("SetMulti with ttl failed: %v", err)
+	}
+	advanceTime(2 * time.Second)
+	got, err = bc.GetMulti([]string{"t1", "t2"})
+	if err != nil {
+		t.Fatalf("GetMulti after expiry failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Expected expired batch to be gone, got %v", got)
+	}
+
+	// a ttl of 0 drops the expiry of an earlier write
+	bc.SetMulti(map[string]interface{}{"t1": "x"}, 1*time.Second)
+	if err := bc.SetMulti(map[string]interface{}{"t1": "z"}, 0); err != nil {
+		t.Fatalf("SetMulti without ttl failed: %v", err)
+	}
+	advanceTime(2 * time.Second)
+	if val, err := c.Get("t1"); err != nil || val != "z" {
+		t.Errorf("Expected 'z' to outlive the old ttl, got %v, %v", val, err)
+	}
+
+	// empty keys are rejected
+	if _, err := bc.GetMulti([]string{"b3", ""}); err != cache.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey for GetMulti, got %v", err)
+	}
+	if err := bc.SetMulti(map[string]interface{}{"": "v"}, 0); err != cache.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey for SetMulti, got %v", err)
+	}
+	if err := bc.DeleteMulti([]string{""}); err != cache.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey for DeleteMulti, got %v", err)
+	}
+}
+
+func testStats(t *testing.T, c cache.Cache, sp cache.StatsProvider) {
+	before := sp.Stats()
+
+	c.Set("key-stats", "val")
+	c.Get("key-stats")
+	c.Get("key-stats-missing")
+	c.Delete("key-stats")
+
+	// counters only ever grow, a shared server may add more than we did
+	after := sp.Stats()
+	if after.Sets-before.Sets < 1 {
+		t.Errorf("Expected Sets to grow, got %d -> %d", before.Sets, after.Sets)
+	}
+	if after.Hits-before.Hits < 1 {
+		t.Errorf("Expected Hits to grow, got %d -> %d", before.Hits, after.Hits)
+	}
+	if after.Misses-before.Misses < 1 {
+		t.Errorf("Expected Misses to grow, got %d -> %d", before.Misses, after.Misses)
+	}
+	if after.Deletes-before.Deletes < 1 {
+		t.Errorf("Expected Deletes to grow, got %d -> %d", before.Deletes, after.Deletes)
+	}
+	if after.Items < -1 || after.Bytes < -1 {
+		t.Errorf("Expected Items and Bytes to be -1 or a size, got %d and %d", after.Items, after.Bytes)
+	}
+}
+
+func testTags(t *testing.T, c cache.Cache, tc cache.TagCache, advanceTime func(time.Duration)) {
+	if err := tc.SetWithTags("p1", "v1", time.Minute, "product:42", "page"); err != nil {
+		t.Fatalf("SetWithTags failed: %v", err)
+	}
+	tc.SetWithTags("p2", "v2", 0, "product:42")
+	tc.SetWithTags("p3", "v3", 0, "page")
+	c.Set("untagged", "v")
+
+	if val, err := c.Get("p1"); err != nil || val != "v1" {
+		t.Fatalf("Expected tagged value 'v1', got %v, %v", val, err)
+	}
+
+	if err := tc.InvalidateTag("product:42"); err != nil {
+		t.Fatalf("InvalidateTag failed: %v", err)
+	}
+	for _, key := range []string{"p1", "p2"} {
+		if _, err := c.Get(key); err != cache.ErrKeyNotFound {
+			t.Errorf("Expected %s gone after InvalidateTag, got %v", key, err)
+		}
+	}
+	for _, key := range []string{"p3", "untagged"} {
+		if _, err := c.Get(key); err != nil {
+			t.Errorf("Expected %s to survive, got %v", key, err)
+		}
+	}
+
+	// the tag keeps working after an invalidation
+	tc.SetWithTags("p1", "v1", 0, "product:42")
+	if val, err := c.Get("p1"); err != nil || val != "v1" {
+		t.Errorf("Expected 'v1' after re-tagging, got %v, %v", val, err)
+	}
+
+	// a ttl of 0 drops the expiry of an earlier write
+	tc.SetWithTags("p4", "old", 1*time.Second, "page")
+	tc.SetWithTags("p4", "new", 0, "page")
+	advanceTime(2 * time.Second)
+	if val, err := c.Get("p4"); err != nil || val != "new" {
+		t.Errorf("Expected 'new' to outlive the old ttl, got %v, %v", val, err)
+	}
+
+	if err := tc.InvalidateTag("no-such-tag"); err != nil {
+		t.Errorf("Expected nil for an unknown tag, got %v", err)
+	}
+	if err := tc.SetWithTags("", "v", 0, "t"); err != cache.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey, got %v", err)
+	}
+	if err := tc.SetWithTags("k", "v", 0, ""); err != cache.ErrEmptyTag {
+		t.Errorf("Expected ErrEmptyTag, got %v", err)
+	}
+	if err := tc.InvalidateTag(""); err != cache.ErrEmptyTag {
+		t.Errorf("Expected ErrEmptyTag for InvalidateTag, got %v", err)
+	}
+}
+
+// counters stay at or above 0, memcached cannot go below it
+func testCounters(t *testing.T, c cache.Cache, cc cache.CounterCache, advanceTime func(time.Duration)) {
+	if n, err := cc.Incr("hits", 5); err != nil || n != 5 {
+		t.Fatalf("Expected 5 from a new counter, got %d, %v", n, err)
+	}
+	if n, err := cc.Incr("hits", 3); err != nil || n != 8 {
+		t.Errorf("Expected 8, got %d, %v", n, err)
+	}
+	if n, err := cc.Decr("hits", 2); err != nil || n != 6 {
+		t.Errorf("Expected 6, got %d, %v", n, err)
+	}
+
+	// concurrent updates are not lost
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				cc.Incr("views", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, err := cc.Incr("views", 0); err != nil || n != 100 {
+		t.Errorf("Expected 100 after concurrent increments, got %d, %v", n, err)
+	}
+
+	// the ttl is only given on create
+	if n, err := cc.IncrWithTTL("rate", 1, 1*time.Second); err != nil || n != 1 {
+		t.Fatalf("Expected 1 from IncrWithTTL, got %d, %v", n, err)
+	}
+	if n, err := cc.IncrWithTTL("rate", 1, time.Hour); err != nil || n != 2 {
+		t.Errorf("Expected 2, got %d, %v", n, err)
+	}
+	advanceTime(2 * time.Second)
+	if n, err := cc.IncrWithTTL("rate", 1, time.Hour); err != nil || n != 1 {
+		t.Errorf("Expected the counter to restart after its ttl, got %d, %v", n, err)
+	}
+
+	c.Set("word", "abc")
+	if _, err := cc.Incr("word", 1); err != cache.ErrNotInteger {
+		t.Errorf("Expected ErrNotInteger, got %v", err)
+	}
+	if _, err := cc.Incr("", 1); err != cache.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey, got %v", err)
+	}
+}
+
+func testConditional(t *testing.T, c cache.Cache, cc cache.ConditionalCache, advanceTime func(time.Duration)) {
+	if err := cc.Add("idem", "first", 0); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := cc.Add("idem", "second", 0); err != cache.ErrKeyExists {
+		t.Errorf("Expected ErrKeyExists, got %v", err)
+	}
+	if val, _ := c.Get("idem"); val != "first" {
+		t.Errorf("Expected Add to keep 'first', got %v", val)
+	}
+
+	if err := cc.Replace("missing", "v", 0); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for Replace, got %v", err)
+	}
+	if _, err := c.Get("missing"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected Replace not to create the key, got %v", err)
+	}
+	if err := cc.Replace("idem", "replaced", 0); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	if val, _ := c.Get("idem"); val != "replaced" {
+		t.Errorf("Expected 'replaced', got %v", val)
+	}
+
+	if ok, err := cc.CompareAndSwap("idem", "stale", "v2", 0); ok || err != nil {
+		t.Errorf("Expected no swap for a stale value, got %v, %v", ok, err)
+	}
+	if ok, err := cc.CompareAndSwap("idem", "replaced", "v2", 0); !ok || err != nil {
+		t.Fatalf("Expected a swap, got %v, %v", ok, err)
+	}
+	if val, _ := c.Get("idem"); val != "v2" {
+		t.Errorf("Expected 'v2' after the swap, got %v", val)
+	}
+	if ok, err := cc.CompareAndSwap("missing", "a", "b", 0); ok || err != cache.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for CompareAndSwap, got %v, %v", ok, err)
+	}
+
+	// only one of many racing writers wins an Add
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wins := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cc.Add("race", "v", 0) == nil {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if wins != 1 {
+		t.Errorf("Expected exactly one Add to win, got %d", wins)
+	}
+
+	// an expired key can be added again
+	if err := cc.Add("short", "v", 1*time.Second); err != nil {
+		t.Fatalf("Add with ttl failed: %v", err)
+	}
+	advanceTime(2 * time.Second)
+	if err := cc.Add("short", "again", 0); err != nil {
+		t.Errorf("Expected Add to succeed after expiry, got %v", err)
+	}
+
+	if err := cc.Add("", "v", 0); err != cache.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey for Add, got %v", err)
+	}
+	if err := cc.Replace("", "v", 0); err != cache.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey for Replace, got %v", err)
+	}
+	if _, err := cc.CompareAndSwap("", "a", "b", 0); err != cache.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey for CompareAndSwap, got %v", err)
+	}
+}
+
+func testIteration(t *testing.T, c cache.Cache, ic cache.IterableCache) {
+	c.Set("user:1", "ana")
+	c.Set("user:2", "bob")
+	c.Set("order:1", "book")
+
+	keys := slices.Sorted(ic.Keys("user:"))
+	if !slices.Equal(keys, []string{"user:1", "user:2"}) {
+		t.Errorf("Expected [user:1 user:2], got %v", keys)
+	}
+	all := slices.Compact(slices.Sorted(ic.Keys("")))
+	if !slices.Equal(all, []string{"order:1", "user:1", "user:2"}) {
+		t.Errorf("Expected every key, got %v", all)
+	}
+
+	got := make(map[string]interface{})
+	for key, val := range ic.Range("user:") {
+		got[key] = val
+	}
+	if len(got) != 2 || got["user:1"] != "ana" || got["user:2"] != "bob" {
+		t.Errorf("Expected user:1=ana user:2=bob, got %v", got)
+	}
+
+	// stopping early is honoured
+	n := 0
+	for range ic.Keys("") {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("Expected the loop to stop after one key, got %d", n)
+	}
+
+	c.Delete("user:1")
+	if keys := slices.Collect(ic.Keys("user:1")); len(keys) != 0 {
+		t.Errorf("Expected deleted key to be gone, got %v", keys)
+	}
+}
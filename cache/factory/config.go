@@ -1,5 +1,10 @@
 package factory
 
+import (
+	"Go-library/cache"
+	"time"
+)
+
 // Type of cache,chooses the backend it want to use
 type BackendType string
 
@@ -7,12 +12,29 @@ const (
 	Memory    BackendType = "memory"
 	Redis     BackendType = "redis"
 	Memcached BackendType = "memcached"
+	// memory L1 in front of a remote L2, see TieredRemote
+	Tiered BackendType = "tiered"
 )
 
 // configuration of the avaliable backend
 type Config struct {
 	// In-Memory  config
 	MemoryMaxSize int
+	// total byte budget for the values held, 0 means no limit
+	MemoryMaxBytes int64
+	// eviction policy: "lru" (default), "lfu" or "tinylfu"
+	MemoryPolicy string
+	// above 1 the keys are spread over this many independently locked
+	// shards (rounded up to a power of two), limits are split between them.
+	// each shard holds at least one item, so a limit below the shard count
+	// is exceeded
+	MemoryShards int
+	// how often expired entries are purged in the background, 0 disables it.
+	// call Close on the returned cache to stop the janitor.
+	MemoryCleanupInterval time.Duration
+	// time source for TTLs, nil means cache.SystemClock. a cache.FakeClock
+	// lets tests expire entries without sleeping.
+	MemoryClock cache.Clock
 
 	// Redis  config
 	RedisAddr     string
@@ -21,6 +43,25 @@ type Config struct {
 
 	// Memcached  config
 	MemcachedServers []string
+
+	// Tiered config, L1 is built from the memory settings above
+	// remote L2 backend, Redis (default) or Memcached
+	TieredRemote BackendType
+	// how long L1 serves a value without asking L2, 0 means tiered.DefaultL1TTL
+	TieredL1TTL time.Duration
+	// redis pub/sub channel used to drop stale L1 copies in other processes,
+	// empty disables it. needs a redis remote, call Close on the cache to
+	// unsubscribe.
+	TieredInvalidationChannel string
+
+	// prefix for the keys of redis and memcached, Clear then removes only
+	// this namespace. redis rejects a ':' in it. the memory cache is
+	// private to the process and ignores it.
+	Namespace string
+
+	// serialization used by redis and memcached:
+	// "json" (default), "gob", "msgpack" or "raw"
+	Codec string
 }
 
 // returns default config
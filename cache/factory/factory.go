@@ -2,10 +2,14 @@ package factory
 
 import (
 	"Go-library/cache"
+	"Go-library/cache/cache/codec"
 	"Go-library/cache/cache/memcached"
 	"Go-library/cache/cache/memory"
 	"Go-library/cache/cache/redis"
+	"Go-library/cache/cache/tiered"
 	"errors"
+	"io"
+	"time"
 
 	gormemcache "github.com/bradfitz/gomemcache/memcache"
 )
@@ -14,21 +18,23 @@ import (
 func New(t BackendType, cfg Config) (cache.Cache, error) {
 	switch t {
 	case Memory:
-		c := memory.NewMemorycache()
-		if cfg.MemoryMaxSize > 0 {
-			c.SetMaxSize(cfg.MemoryMaxSize)
-		}
-		return c, nil
+		return newMemory(cfg)
 
 	case Redis:
 		if cfg.RedisAddr == "" {
 			return nil, errors.New("redis address is required")
 		}
+		cd, err := codec.ByName(cfg.Codec)
+		if err != nil {
+			return nil, err
+		}
 		// Redis package expects its own RedisConfig struct
 		rConfig := redis.RedisConfig{
-			Addr:     cfg.RedisAddr,
-			Password: cfg.RedisPassword,
-			DB:       cfg.RedisDB,
+			Addr:      cfg.RedisAddr,
+			Password:  cfg.RedisPassword,
+			DB:        cfg.RedisDB,
+			Codec:     cd,
+			Namespace: cfg.Namespace,
 		}
 		return redis.NewRedisCache(rConfig)
 
@@ -36,10 +42,109 @@ func New(t BackendType, cfg Config) (cache.Cache, error) {
 		if len(cfg.MemcachedServers) == 0 {
 			return nil, errors.New("at least one memcached server is required")
 		}
+		cd, err := codec.ByName(cfg.Codec)
+		if err != nil {
+			return nil, err
+		}
 		client := gormemcache.New(cfg.MemcachedServers...)
-		return memcached.New(client), nil
+		c := memcached.New(client)
+		c.SetCodec(cd)
+		c.SetNamespace(cfg.Namespace)
+		return c, nil
+
+	case Tiered:
+		return newTiered(cfg)
 
 	default:
 		return nil, errors.New("unsupported backend type")
 	}
 }
+
+// newL1 builds the local tier, tests replace it to watch the tier.
+var newL1 = newMemory
+
+func newTiered(cfg Config) (cache.Cache, error) {
+	remote := cfg.TieredRemote
+	if remote == "" {
+		remote = Redis
+	}
+	if remote != Redis && remote != Memcached {
+		return nil, errors.New("tiered remote must be redis or memcached")
+	}
+	if cfg.TieredInvalidationChannel != "" && remote != Redis {
+		return nil, errors.New("tiered invalidation needs a redis remote")
+	}
+	l1, err := newL1(cfg)
+	if err != nil {
+		return nil, err
+	}
+	l2, err := New(remote, cfg)
+	if err != nil {
+		// stops the janitor l1 may have started
+		closeCache(l1)
+		return nil, err
+	}
+	tc := tiered.New(l1, l2, cfg.TieredL1TTL)
+	if cfg.TieredInvalidationChannel != "" {
+		bus, err := redis.NewInvalidationBus(l2.(*redis.RedisCache), cfg.TieredInvalidationChannel)
+		if err != nil {
+			closeCache(l1)
+			closeCache(l2)
+			return nil, err
+		}
+		tc.SetInvalidator(bus)
+	}
+	return tc, nil
+}
+
+// closeCache closes c when it can be closed.
+func closeCache(c cache.Cache) {
+	if cl, ok := c.(io.Closer); ok {
+		cl.Close()
+	}
+}
+
+// tuning knobs shared by the plain and the sharded memory cache
+type memoryBackend interface {
+	cache.Cache
+	SetMaxSize(size int)
+	SetMaxCost(maxCost int64)
+	StartJanitor(interval time.Duration)
+	SetClock(clock cache.Clock)
+}
+
+func newMemory(cfg Config) (cache.Cache, error) {
+	// shards build their own policy instances, so check the name up front
+	if _, err := memory.PolicyByName(cfg.MemoryPolicy, 0); err != nil {
+		return nil, err
+	}
+	var c memoryBackend
+	if cfg.MemoryShards > 1 {
+		s := memory.NewSharded(cfg.MemoryShards)
+		// NewSharded rounds the count up, size the policies by the real one
+		perShard := (cfg.MemoryMaxSize + s.Shards() - 1) / s.Shards()
+		s.SetPolicy(func() memory.Policy {
+			p, _ := memory.PolicyByName(cfg.MemoryPolicy, perShard)
+			return p
+		})
+		c = s
+	} else {
+		m := memory.NewMemorycache()
+		p, _ := memory.PolicyByName(cfg.MemoryPolicy, cfg.MemoryMaxSize)
+		m.SetPolicy(p)
+		c = m
+	}
+	if cfg.MemoryClock != nil {
+		c.SetClock(cfg.MemoryClock)
+	}
+	if cfg.MemoryMaxSize > 0 {
+		c.SetMaxSize(cfg.MemoryMaxSize)
+	}
+	if cfg.MemoryMaxBytes > 0 {
+		c.SetMaxCost(cfg.MemoryMaxBytes)
+	}
+	if cfg.MemoryCleanupInterval > 0 {
+		c.StartJanitor(cfg.MemoryCleanupInterval)
+	}
+	return c, nil
+}
@@ -1,7 +1,14 @@
 package factory
 
 import (
+	"Go-library/cache"
+	"Go-library/cache/cache/memory"
+	"Go-library/cache/cache/tiered"
+	"io"
 	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
 )
 
 func TestNewMemory(t *testing.T) {
@@ -52,3 +59,148 @@ func TestUnknownBackend(t *testing.T) {
 		t.Fatal("Expected error for unknown backend, got nil")
 	}
 }
+
+func TestUnknownCodec(t *testing.T) {
+	_, err := New(Redis, Config{
+		RedisAddr: "localhost:6380",
+		Codec:     "xml",
+	})
+	if err == nil {
+		t.Fatal("Expected error for unknown codec, got nil")
+	}
+}
+
+func TestMemoryPolicy(t *testing.T) {
+	for _, policy := range []string{"", "lru", "lfu", "tinylfu"} {
+		c, err := New(Memory, Config{MemoryMaxSize: 10, MemoryPolicy: policy})
+		if err != nil {
+			t.Fatalf("Failed to create memory cache with policy %q: %v", policy, err)
+		}
+		if err := c.Set("foo", "bar"); err != nil {
+			t.Errorf("Set failed with policy %q: %v", policy, err)
+		}
+	}
+	if _, err := New(Memory, Config{MemoryPolicy: "fifo"}); err == nil {
+		t.Fatal("Expected error for unknown policy, got nil")
+	}
+}
+
+func TestNewMemorySharded(t *testing.T) {
+	c, err := New(Memory, Config{
+		MemoryMaxSize: 100,
+		MemoryShards:  8,
+		MemoryPolicy:  "lfu",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create sharded memory cache: %v", err)
+	}
+	if _, ok := c.(*memory.ShardedCache); !ok {
+		t.Fatalf("Expected *memory.ShardedCache, got %T", c)
+	}
+	if err := c.Set("foo", "bar"); err != nil {
+		t.Errorf("Set failed: %v", err)
+	}
+}
+
+func TestNewTiered(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	c, err := New(Tiered, Config{
+		RedisAddr:   mr.Addr(),
+		TieredL1TTL: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tiered cache: %v", err)
+	}
+	tc, ok := c.(*tiered.Cache)
+	if !ok {
+		t.Fatalf("Expected *tiered.Cache, got %T", c)
+	}
+	if err := c.Set("foo", "bar"); err != nil {
+		t.Errorf("Set failed: %v", err)
+	}
+	if !mr.Exists("foo") {
+		t.Error("Expected write through to redis")
+	}
+	if val, err := tc.L1().Get("foo"); err != nil || val != "bar" {
+		t.Errorf("Expected foo in L1, got %v, %v", val, err)
+	}
+
+	c, err = New(Tiered, Config{
+		RedisAddr:                 mr.Addr(),
+		TieredInvalidationChannel: "invalidations",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tiered cache with invalidation: %v", err)
+	}
+	if err := c.(*tiered.Cache).Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+
+	if _, err := New(Tiered, Config{TieredRemote: Memory}); err == nil {
+		t.Fatal("Expected error for memory as tiered remote, got nil")
+	}
+	if _, err := New(Tiered, Config{
+		TieredRemote:              Memcached,
+		MemcachedServers:          []string{"localhost:11211"},
+		TieredInvalidationChannel: "invalidations",
+	}); err == nil {
+		t.Fatal("Expected error for invalidation without a redis remote, got nil")
+	}
+}
+
+// records whether the factory closed it
+type closeProbe struct {
+	cache.Cache
+	closed bool
+}
+
+func (p *closeProbe) Close() error {
+	p.closed = true
+	return p.Cache.(io.Closer).Close()
+}
+
+// a failing L2 must not leave the L1 janitor running
+func TestNewTieredClosesL1(t *testing.T) {
+	var l1 *closeProbe
+	newL1 = func(cfg Config) (cache.Cache, error) {
+		c, err := newMemory(cfg)
+		l1 = &closeProbe{Cache: c}
+		return l1, err
+	}
+	t.Cleanup(func() { newL1 = newMemory })
+
+	if _, err := New(Tiered, Config{MemoryCleanupInterval: time.Minute}); err == nil {
+		t.Fatal("Expected error without a redis address, got nil")
+	}
+	if l1 == nil || !l1.closed {
+		t.Error("Expected L1 closed after the L2 failed")
+	}
+}
+func TestNamespace(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	c, err := New(Redis, Config{RedisAddr: mr.Addr(), Namespace: "billing"})
+	if err != nil {
+		t.Fatalf("Failed to create redis cache: %v", err)
+	}
+	mr.Set("other", "x")
+	c.Set("foo", "bar")
+	if !mr.Exists("billing:foo") {
+		t.Error("Expected namespaced key billing:foo")
+	}
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if !mr.Exists("other") || mr.Exists("billing:foo") {
+		t.Errorf("Expected Clear to remove only the namespace, left %v", mr.Keys())
+	}
+}
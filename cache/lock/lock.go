@@ -0,0 +1,161 @@
+// Package lock provides named locks shared by every process using the same
+// backend, for jobs that must not run concurrently.
+//
+// every lock has a ttl so a crashed holder cannot keep it forever. a holder
+// that outlives its ttl loses the lock without noticing, so writes it guards
+// should carry the lock's fencing token and the store should reject tokens
+// lower than the last one it saw. memcached may evict its fencing counter,
+// which then restarts from the clock.
+//
+// locks are kept apart from the cached values: clearing the cache does not
+// release them, except for a memcached Clear without a namespace, which
+// flushes the whole server.
+package lock
+
+import (
+	"Go-library/cache/cache/memcached"
+	"Go-library/cache/cache/memory"
+	"Go-library/cache/cache/redis"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	// TryAcquire found the lock held by someone else
+	ErrNotAcquired = errors.New("lock: held by another owner")
+	// the lock expired or was taken over before Refresh or Release
+	ErrLockLost = errors.New("lock: no longer held")
+	// Acquire and TryAcquire were given a ttl <= 0
+	ErrInvalidTTL = errors.New("lock: ttl must be positive")
+)
+
+// DefaultRetryInterval is how often Acquire retries a held lock.
+const DefaultRetryInterval = 50 * time.Millisecond
+
+// Backend stores the locks. owner identifies the holder, only it can
+// refresh or release the lock.
+type Backend interface {
+	// TryLock takes name for owner unless it is held and returns a fencing
+	// token larger than any given out before for name. ttl is positive.
+	TryLock(ctx context.Context, name, owner string, ttl time.Duration) (token int64, ok bool, err error)
+	// RefreshLock gives the lock a new ttl if owner holds it.
+	RefreshLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+	// Unlock releases the lock if owner holds it.
+	Unlock(ctx context.Context, name, owner string) (bool, error)
+}
+
+var _ Backend = (*redis.RedisCache)(nil)
+var _ Backend = (*memcached.MemcachedCache)(nil)
+var _ Backend = (*memory.Memorycache)(nil)
+
+// Locker hands out locks stored in a Backend.
+type Locker struct {
+	backend Backend
+	retry   time.Duration
+}
+
+// New returns a Locker storing its locks in b.
+func New(b Backend) *Locker {
+	return &Locker{backend: b, retry: DefaultRetryInterval}
+}
+
+// SetRetryInterval changes how often Acquire retries, <= 0 restores
+// DefaultRetryInterval.
+func (l *Locker) SetRetryInterval(d time.Duration) {
+	if d <= 0 {
+		d = DefaultRetryInterval
+	}
+	l.retry = d
+}
+
+// Acquire waits until it holds the lock name or ctx is done, in which case
+// it returns ctx.Err(). the lock expires after ttl unless refreshed, a ttl
+// <= 0 returns ErrInvalidTTL.
+func (l *Locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidTTL
+	}
+	ticker := time.NewTicker(l.retry)
+	defer ticker.Stop()
+	for {
+		lk, err := l.TryAcquire(ctx, name, ttl)
+		if !errors.Is(err, ErrNotAcquired) {
+			return lk, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// TryAcquire takes the lock name once, ErrNotAcquired if it is held.
+func (l *Locker) TryAcquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidTTL
+	}
+	owner := newOwner()
+	token, ok, err := l.backend.TryLock(ctx, name, owner, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+	return &Lock{backend: l.backend, name: name, owner: owner, token: token, ttl: ttl}, nil
+}
+
+// Lock is a held lock.
+type Lock struct {
+	backend Backend
+	name    string
+	owner   string
+	token   int64
+	ttl     time.Duration
+}
+
+// Name returns the name the lock was acquired under.
+func (lk *Lock) Name() string {
+	return lk.name
+}
+
+// Token returns the fencing token, larger than the token of any earlier
+// holder of the same name.
+func (lk *Lock) Token() int64 {
+	return lk.token
+}
+
+// Refresh restarts the lock's ttl, ErrLockLost if it is no longer held.
+func (lk *Lock) Refresh(ctx context.Context) error {
+	ok, err := lk.backend.RefreshLock(ctx, lk.name, lk.owner, lk.ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Release frees the lock, ErrLockLost if it was no longer held.
+func (lk *Lock) Release(ctx context.Context) error {
+	ok, err := lk.backend.Unlock(ctx, lk.name, lk.owner)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// newOwner returns a random id for a holder.
+func newOwner() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,230 @@
+package lock
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/memcached"
+	"Go-library/cache/cache/memory"
+	"Go-library/cache/cache/redis"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// every backend with a way to let its locks expire
+func backends() map[string]func(t *testing.T) (Backend, func(time.Duration)) {
+	return map[string]func(t *testing.T) (Backend, func(time.Duration)){
+		"memory": func(t *testing.T) (Backend, func(time.Duration)) {
+			mc := memory.NewMemorycache()
+			clock := cache.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			mc.SetClock(clock)
+			return mc, clock.Advance
+		},
+		"redis": func(t *testing.T) (Backend, func(time.Duration)) {
+			return newRedis(t, "")
+		},
+		"redis-namespaced": func(t *testing.T) (Backend, func(time.Duration)) {
+			return newRedis(t, "svc")
+		},
+		"memcached": func(t *testing.T) (Backend, func(time.Duration)) {
+			client := memcache.New("localhost:11211")
+			if err := client.Ping(); err != nil {
+				t.Skip("Memcached is not running on localhost:11211, skipping lock tests")
+			}
+			mc := memcached.New(client)
+			// without a namespace Clear would flush the locks too
+			mc.SetNamespace("locktest")
+			mc.Clear()
+			return mc, time.Sleep
+		},
+	}
+}
+
+func newRedis(t *testing.T, namespace string) (Backend, func(time.Duration)) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	rc, err := redis.NewRedisCache(redis.RedisConfig{Addr: mr.Addr(), Namespace: namespace})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	return rc, mr.FastForward
+}
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	for name, setup := range backends() {
+		t.Run(name, func(t *testing.T) {
+			b, advanceTime := setup(t)
+			l := New(b)
+
+			first, err := l.TryAcquire(ctx, "job", time.Minute)
+			if err != nil {
+				t.Fatalf("TryAcquire failed: %v", err)
+			}
+			if _, err := l.TryAcquire(ctx, "job", time.Minute); !errors.Is(err, ErrNotAcquired) {
+				t.Errorf("Expected ErrNotAcquired while held, got %v", err)
+			}
+			// other names are independent
+			other, err := l.TryAcquire(ctx, "other-job", time.Minute)
+			if err != nil {
+				t.Fatalf("TryAcquire of another name failed: %v", err)
+			}
+			other.Release(ctx)
+
+			if err := first.Refresh(ctx); err != nil {
+				t.Errorf("Refresh failed: %v", err)
+			}
+			if err := first.Release(ctx); err != nil {
+				t.Fatalf("Release failed: %v", err)
+			}
+			if err := first.Release(ctx); !errors.Is(err, ErrLockLost) {
+				t.Errorf("Expected ErrLockLost on a second Release, got %v", err)
+			}
+
+			second, err := l.TryAcquire(ctx, "job", 1*time.Second)
+			if err != nil {
+				t.Fatalf("TryAcquire after Release failed: %v", err)
+			}
+			if second.Token() <= first.Token() {
+				t.Errorf("Expected tokens to grow, got %d then %d", first.Token(), second.Token())
+			}
+
+			// an expired lock is lost to its holder and free for others
+			advanceTime(2 * time.Second)
+			third, err := l.TryAcquire(ctx, "job", time.Minute)
+			if err != nil {
+				t.Fatalf("TryAcquire after expiry failed: %v", err)
+			}
+			if err := second.Refresh(ctx); !errors.Is(err, ErrLockLost) {
+				t.Errorf("Expected ErrLockLost refreshing an expired lock, got %v", err)
+			}
+			if err := second.Release(ctx); !errors.Is(err, ErrLockLost) {
+				t.Errorf("Expected ErrLockLost releasing an expired lock, got %v", err)
+			}
+			if third.Token() <= second.Token() {
+				t.Errorf("Expected tokens to grow, got %d then %d", second.Token(), third.Token())
+			}
+			third.Release(ctx)
+		})
+	}
+}
+
+func TestClearKeepsLocks(t *testing.T) {
+	ctx := context.Background()
+	for name, setup := range backends() {
+		t.Run(name, func(t *testing.T) {
+			b, _ := setup(t)
+			l := New(b)
+
+			first, err := l.TryAcquire(ctx, "job", time.Minute)
+			if err != nil {
+				t.Fatalf("TryAcquire failed: %v", err)
+			}
+			if err := b.(cache.Cache).Clear(); err != nil {
+				t.Fatalf("Clear failed: %v", err)
+			}
+			if _, err := l.TryAcquire(ctx, "job", time.Minute); !errors.Is(err, ErrNotAcquired) {
+				t.Errorf("Expected ErrNotAcquired after Clear, got %v", err)
+			}
+			if err := first.Release(ctx); err != nil {
+				t.Fatalf("Release after Clear failed: %v", err)
+			}
+
+			if err := b.(cache.Cache).Clear(); err != nil {
+				t.Fatalf("Clear failed: %v", err)
+			}
+			second, err := l.TryAcquire(ctx, "job", time.Minute)
+			if err != nil {
+				t.Fatalf("TryAcquire after Release failed: %v", err)
+			}
+			if second.Token() <= first.Token() {
+				t.Errorf("Expected tokens to grow across Clear, got %d then %d", first.Token(), second.Token())
+			}
+			second.Release(ctx)
+		})
+	}
+}
+
+func TestInvalidTTL(t *testing.T) {
+	ctx := context.Background()
+	l := New(memory.NewMemorycache())
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		if _, err := l.TryAcquire(ctx, "job", ttl); !errors.Is(err, ErrInvalidTTL) {
+			t.Errorf("Expected ErrInvalidTTL from TryAcquire(%v), got %v", ttl, err)
+		}
+		if _, err := l.Acquire(ctx, "job", ttl); !errors.Is(err, ErrInvalidTTL) {
+			t.Errorf("Expected ErrInvalidTTL from Acquire(%v), got %v", ttl, err)
+		}
+	}
+}
+
+func TestAcquireWaits(t *testing.T) {
+	ctx := context.Background()
+	l := New(memory.NewMemorycache())
+	l.SetRetryInterval(time.Millisecond)
+
+	held, err := l.Acquire(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		held.Release(ctx)
+	}()
+	next, err := l.Acquire(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire after release failed: %v", err)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(short, "job", time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded while held, got %v", err)
+	}
+	next.Release(ctx)
+}
+
+func TestMutualExclusion(t *testing.T) {
+	ctx := context.Background()
+	l := New(memory.NewMemorycache())
+	l.SetRetryInterval(time.Millisecond)
+
+	var wg sync.WaitGroup
+	var inside, maxInside, last int64
+	var mu sync.Mutex
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lk, err := l.Acquire(ctx, "job", time.Minute)
+			if err != nil {
+				t.Errorf("Acquire failed: %v", err)
+				return
+			}
+			mu.Lock()
+			inside++
+			maxInside = max(maxInside, inside)
+			if lk.Token() <= last {
+				t.Errorf("Expected token above %d, got %d", last, lk.Token())
+			}
+			last = lk.Token()
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			inside--
+			mu.Unlock()
+			lk.Release(ctx)
+		}()
+	}
+	wg.Wait()
+	if maxInside != 1 {
+		t.Errorf("Expected one holder at a time, got %d", maxInside)
+	}
+}
@@ -0,0 +1,151 @@
+package memcached
+
+import (
+	"Go-library/cache"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// an item hidden by an invalidated tag still exists in memcached, so Add
+// takes its place and Replace and CompareAndSwap treat it as missing. every
+// rewrite of an existing item goes through cas and is retried when another
+// writer got in between.
+
+var _ cache.ConditionalCache = (*MemcachedCache)(nil)
+
+// Add stores value with memcached's add.
+func (c *MemcachedCache) Add(key string, value interface{}, ttl time.Duration) error {
+	if key == "" {
+		return cache.ErrEmptyKey
+	}
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	prefix, err := c.prefix()
+	if err != nil {
+		return err
+	}
+	for {
+		err := c.client.Add(&memcache.Item{Key: prefix + key, Value: data, Expiration: expiration(ttl)})
+		if err == nil {
+			c.stats.AddSets(1)
+			return nil
+		}
+		if !errors.Is(err, memcache.ErrNotStored) {
+			return err
+		}
+		item, err := c.client.Get(prefix + key)
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, visible, err := c.payload(prefix, item)
+		if err != nil {
+			return err
+		}
+		if visible {
+			return cache.ErrKeyExists
+		}
+		item.Value, item.Flags, item.Expiration = data, 0, expiration(ttl)
+		err = c.client.CompareAndSwap(item)
+		if err == nil {
+			c.stats.AddSets(1)
+			return nil
+		}
+		if !errors.Is(err, memcache.ErrCASConflict) && !errors.Is(err, memcache.ErrCacheMiss) {
+			return err
+		}
+	}
+}
+
+// Replace stores value over a visible item.
+func (c *MemcachedCache) Replace(key string, value interface{}, ttl time.Duration) error {
+	_, err := c.update(key, value, ttl, func([]byte) bool { return true })
+	return err
+}
+
+// CompareAndSwap compares old encoded with the codec byte for byte, so it
+// must encode the way the stored value did.
+func (c *MemcachedCache) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) (bool, error) {
+	oldData, err := c.codec.Marshal(old)
+	if err != nil {
+		return false, err
+	}
+	return c.update(key, new, ttl, func(cur []byte) bool {
+		return bytes.Equal(cur, oldData)
+	})
+}
+
+// update rewrites the visible item under key with value if accept agrees
+// with its current bytes. a missing or hidden item returns ErrKeyNotFound.
+func (c *MemcachedCache) update(key string, value interface{}, ttl time.Duration, accept func(cur []byte) bool) (bool, error) {
+	if key == "" {
+		return false, cache.ErrEmptyKey
+	}
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	prefix, err := c.prefix()
+	if err != nil {
+		return false, err
+	}
+	for {
+		item, err := c.client.Get(prefix + key)
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return false, cache.ErrKeyNotFound
+		}
+		if err != nil {
+			return false, err
+		}
+		cur, visible, err := c.payload(prefix, item)
+		if err != nil {
+			return false, err
+		}
+		if !visible {
+			return false, cache.ErrKeyNotFound
+		}
+		if !accept(cur) {
+			return false, nil
+		}
+		item.Value, item.Flags, item.Expiration = data, 0, expiration(ttl)
+		err = c.client.CompareAndSwap(item)
+		switch {
+		case err == nil:
+			c.stats.AddSets(1)
+			return true, nil
+		case errors.Is(err, memcache.ErrCacheMiss):
+			return false, cache.ErrKeyNotFound
+		case !errors.Is(err, memcache.ErrCASConflict):
+			return false, err
+		}
+	}
+}
+
+// payload returns the codec bytes of item and whether it can be read, a
+// tagged item whose tags were invalidated cannot.
+func (c *MemcachedCache) payload(prefix string, item *memcache.Item) ([]byte, bool, error) {
+	if item.Flags&flagTagged == 0 {
+		return item.Value, true, nil
+	}
+	env := &tagEnvelope{}
+	if err := json.Unmarshal(item.Value, env); err != nil {
+		return nil, false, err
+	}
+	keys := make([]string, 0, len(env.Gens))
+	for tag := range env.Gens {
+		keys = append(keys, prefix+tagPrefix+tag)
+	}
+	current, err := c.client.GetMulti(keys)
+	if err != nil {
+		return nil, false, err
+	}
+	return env.Value, fresh(prefix, env, current), nil
+}
@@ -0,0 +1,83 @@
+package memcached
+
+import (
+	"Go-library/cache"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// memcached counters are unsigned: a Decr stops at 0 and a missing key
+// never starts below it. they are stored as decimal text, which the JSON
+// and raw codecs can read back with Get.
+
+var _ cache.CounterCache = (*MemcachedCache)(nil)
+
+// Incr adds delta with memcached's incr, or decr for a negative delta.
+func (c *MemcachedCache) Incr(key string, delta int64) (int64, error) {
+	return c.IncrWithTTL(key, delta, 0)
+}
+
+// Decr subtracts delta, see Incr.
+func (c *MemcachedCache) Decr(key string, delta int64) (int64, error) {
+	return c.IncrWithTTL(key, -delta, 0)
+}
+
+// IncrWithTTL is Incr that expires a key it creates after ttl. a missing
+// key is created with Add, so of two racing creators one falls back to incr.
+func (c *MemcachedCache) IncrWithTTL(key string, delta int64, ttl time.Duration) (int64, error) {
+	if key == "" {
+		return 0, cache.ErrEmptyKey
+	}
+	prefix, err := c.prefix()
+	if err != nil {
+		return 0, err
+	}
+	mkey := prefix + key
+	for {
+		n, err := c.incr(mkey, delta)
+		if err == nil {
+			c.stats.AddSets(1)
+			return n, nil
+		}
+		if !errors.Is(err, memcache.ErrCacheMiss) {
+			return 0, err
+		}
+		n = max(delta, 0)
+		err = c.client.Add(&memcache.Item{
+			Key:        mkey,
+			Value:      []byte(strconv.FormatInt(n, 10)),
+			Expiration: expiration(ttl),
+		})
+		if err == nil {
+			c.stats.AddSets(1)
+			return n, nil
+		}
+		if !errors.Is(err, memcache.ErrNotStored) {
+			return 0, err
+		}
+	}
+}
+
+// DecrWithTTL is Decr that expires a key it creates after ttl.
+func (c *MemcachedCache) DecrWithTTL(key string, delta int64, ttl time.Duration) (int64, error) {
+	return c.IncrWithTTL(key, -delta, ttl)
+}
+
+// incr sends incr or decr depending on the sign of delta.
+func (c *MemcachedCache) incr(mkey string, delta int64) (int64, error) {
+	var n uint64
+	var err error
+	if delta < 0 {
+		n, err = c.client.Decrement(mkey, uint64(-delta))
+	} else {
+		n, err = c.client.Increment(mkey, uint64(delta))
+	}
+	if err != nil && strings.Contains(err.Error(), "non-numeric") {
+		return 0, cache.ErrNotInteger
+	}
+	return int64(n), err
+}
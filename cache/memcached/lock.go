@@ -0,0 +1,119 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// lockPrefix marks the keys of lock.Acquire, keys starting with it are
+// reserved. a lock lives in "__lock:<namespace>:<name>" and its fencing
+// counter in "__lock:<namespace>:<name>:fence", outside the versioned
+// namespace, so a namespaced Clear does not touch them. a Clear without a
+// namespace flushes the server and every lock with it.
+//
+// memcached may evict the counter, or a flush may drop it. it then starts
+// over from the wall clock in microseconds, so tokens keep growing as long
+// as the clocks of the processes agree better than their acquisition rate.
+const lockPrefix = "__lock:"
+
+// lockKey maps a lock name to its memcached key.
+func (c *MemcachedCache) lockKey(name string) string {
+	return lockPrefix + c.namespace + ":" + name
+}
+
+// lockExpiration rounds ttl up to whole seconds, so a lock never expires
+// before its holder's deadline.
+func lockExpiration(ttl time.Duration) int32 {
+	return int32(math.Ceil(ttl.Seconds()))
+}
+
+// TryLock takes the lock name for owner with add unless it is held, and
+// returns the next fencing token for name, see lockPrefix. the ctx is only
+// checked before the requests are sent.
+func (c *MemcachedCache) TryLock(ctx context.Context, name, owner string, ttl time.Duration) (int64, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+	err := c.client.Add(&memcache.Item{Key: c.lockKey(name), Value: []byte(owner), Expiration: lockExpiration(ttl)})
+	if errors.Is(err, memcache.ErrNotStored) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	// only the holder increments, so tokens follow the order of acquisition
+	token, err := c.fence(c.lockKey(name) + ":fence")
+	if err != nil {
+		c.Unlock(ctx, name, owner)
+		return 0, false, err
+	}
+	return token, true, nil
+}
+
+// fence increments the counter under key, creating a missing one from the
+// clock. the counter has no expiry.
+func (c *MemcachedCache) fence(key string) (int64, error) {
+	for {
+		n, err := c.client.Increment(key, 1)
+		if err == nil {
+			return int64(n), nil
+		}
+		if !errors.Is(err, memcache.ErrCacheMiss) {
+			return 0, err
+		}
+		start := time.Now().UnixMicro()
+		err = c.client.Add(&memcache.Item{Key: key, Value: []byte(strconv.FormatInt(start, 10))})
+		if err == nil {
+			return start, nil
+		}
+		if !errors.Is(err, memcache.ErrNotStored) {
+			return 0, err
+		}
+	}
+}
+
+// RefreshLock gives the lock a new ttl with cas if owner still holds it.
+func (c *MemcachedCache) RefreshLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	return c.rewriteLock(ctx, name, owner, lockExpiration(ttl))
+}
+
+// Unlock releases the lock if owner still holds it. memcached cannot delete
+// with cas, so the lock is rewritten with an expiry in the past.
+func (c *MemcachedCache) Unlock(ctx context.Context, name, owner string) (bool, error) {
+	return c.rewriteLock(ctx, name, owner, -1)
+}
+
+// rewriteLock sets the lock's expiration if owner holds it, retrying when
+// the item changed between the get and the cas.
+func (c *MemcachedCache) rewriteLock(ctx context.Context, name, owner string, exp int32) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	for {
+		item, err := c.client.Get(c.lockKey(name))
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if string(item.Value) != owner {
+			return false, nil
+		}
+		item.Expiration = exp
+		err = c.client.CompareAndSwap(item)
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, memcache.ErrCacheMiss):
+			return false, nil
+		case !errors.Is(err, memcache.ErrCASConflict):
+			return false, err
+		}
+	}
+}
@@ -2,6 +2,8 @@ package memcached
 
 import (
 	"Go-library/cache"
+	"Go-library/cache/cache/codec"
+	"context"
 	"errors"
 	"time"
 
@@ -9,20 +11,58 @@ import (
 )
 
 // MemcachedCache (cache.Cache interface)
+// it is not a cache.IterableCache, memcached has no way to list its keys.
 type MemcachedCache struct {
 	client *memcache.Client
+	codec  codec.Codec
+	stats  cache.StatsRecorder
+	// see SetNamespace
+	namespace string
 }
 
 // Ensure MemcachedCache implements cache.Cache
 var _ cache.Cache = (*MemcachedCache)(nil)
+var _ cache.ContextCache = (*MemcachedCache)(nil)
+var _ cache.CapabilityProvider = (*MemcachedCache)(nil)
+
+// Capabilities: no iteration. Clear is scoped with a namespace, and the raw
+// codec limits the values to []byte and string.
+func (c *MemcachedCache) Capabilities() cache.Capabilities {
+	return cache.Capabilities{
+		AnyValue:    c.codec != codec.Raw,
+		Context:     true,
+		Batch:       true,
+		Stats:       true,
+		Tags:        true,
+		Counters:    true,
+		Conditional: true,
+		Distributed: true,
+		ScopedClear: c.namespace != "",
+	}
+}
 
 // constructor for memcache
 func New(client *memcache.Client) *MemcachedCache {
 	return &MemcachedCache{
 		client: client,
+		codec:  codec.JSON,
 	}
 }
 
+// SetCodec changes how values are serialized, nil restores codec.JSON.
+// values written with one codec cannot be read back with another.
+func (c *MemcachedCache) SetCodec(cd codec.Codec) {
+	if cd == nil {
+		cd = codec.JSON
+	}
+	c.codec = cd
+}
+
+// Codec returns the codec values are stored with.
+func (c *MemcachedCache) Codec() codec.Codec {
+	return c.codec
+}
+
 // sets add new value or update the old value
 func (c *MemcachedCache) Set(key string, value interface{}) error {
 	return c.SetWithTTL(key, value, 0)
@@ -34,23 +74,26 @@ func (c *MemcachedCache) SetWithTTL(key string, value interface{}, ttl time.Dura
 		return cache.ErrEmptyKey
 	}
 
-	valStr, ok := value.(string)
-	if !ok {
-		return errors.New("memcached client only supports string values for now")
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
 	}
-
-	sec := int32(ttl.Seconds())
-	if ttl > 0 && sec == 0 {
-		sec = 1
+	prefix, err := c.prefix()
+	if err != nil {
+		return err
 	}
 
 	item := &memcache.Item{
-		Key:        key,
-		Value:      []byte(valStr),
-		Expiration: sec,
+		Key:        prefix + key,
+		Value:      data,
+		Expiration: expiration(ttl),
 	}
 
-	return c.client.Set(item)
+	if err := c.client.Set(item); err != nil {
+		return err
+	}
+	c.stats.AddSets(1)
+	return nil
 }
 
 // Get retrieves a value from the cache.
@@ -59,15 +102,40 @@ func (c *MemcachedCache) Get(key string) (interface{}, error) {
 		return nil, cache.ErrEmptyKey
 	}
 
-	item, err := c.client.Get(key)
+	prefix, err := c.prefix()
+	if err != nil {
+		return nil, err
+	}
+	item, err := c.client.Get(prefix + key)
 	if err != nil {
 		if errors.Is(err, memcache.ErrCacheMiss) {
+			c.stats.AddMisses(1)
 			return nil, cache.ErrKeyNotFound
 		}
 		return nil, err
 	}
+	out, err := c.decodeItems(prefix, map[string]*memcache.Item{key: item})
+	if err != nil {
+		return nil, err
+	}
+	val, ok := out[key]
+	if !ok {
+		// its tags were invalidated
+		c.stats.AddMisses(1)
+		return nil, cache.ErrKeyNotFound
+	}
+	c.stats.AddHits(1)
+	return val, nil
+}
 
-	return string(item.Value), nil
+// expiration converts ttl to memcached seconds, rounding a positive ttl
+// below a second up so it does not mean "never".
+func expiration(ttl time.Duration) int32 {
+	sec := int32(ttl.Seconds())
+	if ttl > 0 && sec == 0 {
+		sec = 1
+	}
+	return sec
 }
 
 // Delete removes a key from the cache.
@@ -76,17 +144,162 @@ func (c *MemcachedCache) Delete(key string) error {
 		return cache.ErrEmptyKey
 	}
 
-	err := c.client.Delete(key)
+	prefix, err := c.prefix()
+	if err != nil {
+		return err
+	}
+	err = c.client.Delete(prefix + key)
 	if err != nil {
 		if errors.Is(err, memcache.ErrCacheMiss) {
 			return cache.ErrKeyNotFound
 		}
 		return err
 	}
+	c.stats.AddDeletes(1)
 	return nil
 }
 
-// Clear removes all keys from the cache.
+// Clear removes all keys from the cache. with a namespace it moves the
+// namespace to a new version instead, see SetNamespace.
 func (c *MemcachedCache) Clear() error {
+	if c.namespace != "" {
+		return c.client.Set(&memcache.Item{Key: c.namespace + nsVersionKey, Value: newGeneration()})
+	}
 	return c.client.DeleteAll()
 }
+
+// the gomemcache client has no context support, so the ctx variants only
+// check for cancellation before issuing the request. the client's own
+// Timeout still bounds the network call.
+
+// SetCtx is Set honouring ctx cancellation.
+func (c *MemcachedCache) SetCtx(ctx context.Context, key string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Set(key, value)
+}
+
+// SetWithTTLCtx is SetWithTTL honouring ctx cancellation.
+func (c *MemcachedCache) SetWithTTLCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+// GetCtx is Get honouring ctx cancellation.
+func (c *MemcachedCache) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Get(key)
+}
+
+// DeleteCtx is Delete honouring ctx cancellation.
+func (c *MemcachedCache) DeleteCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Delete(key)
+}
+
+// ClearCtx is Clear honouring ctx cancellation.
+func (c *MemcachedCache) ClearCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Clear()
+}
+
+var _ cache.BatchCache = (*MemcachedCache)(nil)
+
+// GetMulti fetches all keys with the client's batched get.
+func (c *MemcachedCache) GetMulti(keys []string) (map[string]interface{}, error) {
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, len(keys))
+	if len(keys) == 0 {
+		return out, nil
+	}
+	prefix, err := c.prefix()
+	if err != nil {
+		return nil, err
+	}
+	mkeys := make([]string, len(keys))
+	for i, key := range keys {
+		mkeys[i] = prefix + key
+	}
+	items, err := c.client.GetMulti(mkeys)
+	if err != nil {
+		return nil, err
+	}
+	// back to cache keys
+	byKey := make(map[string]*memcache.Item, len(items))
+	for mkey, item := range items {
+		byKey[mkey[len(prefix):]] = item
+	}
+	if out, err = c.decodeItems(prefix, byKey); err != nil {
+		return nil, err
+	}
+	c.stats.AddHits(int64(len(out)))
+	c.stats.AddMisses(int64(len(keys) - len(out)))
+	return out, nil
+}
+
+// SetMulti writes all items. memcached has no multi-set, so this is one
+// request per item and stops at the first failure.
+func (c *MemcachedCache) SetMulti(items map[string]interface{}, ttl time.Duration) error {
+	for key := range items {
+		if key == "" {
+			return cache.ErrEmptyKey
+		}
+	}
+	for key, value := range items {
+		if err := c.SetWithTTL(key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMulti removes keys one request at a time, missing keys are ignored.
+func (c *MemcachedCache) DeleteMulti(keys []string) error {
+	if err := checkKeys(keys); err != nil {
+		return err
+	}
+	prefix, err := c.prefix()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		err := c.client.Delete(prefix + key)
+		if err == nil {
+			c.stats.AddDeletes(1)
+			continue
+		}
+		if !errors.Is(err, memcache.ErrCacheMiss) {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkKeys(keys []string) error {
+	for _, key := range keys {
+		if key == "" {
+			return cache.ErrEmptyKey
+		}
+	}
+	return nil
+}
+
+var _ cache.StatsProvider = (*MemcachedCache)(nil)
+
+// Stats returns the operations counted by this client. the memcache client
+// has no stats command, so evictions, expirations, items and bytes are not
+// known here (Items and Bytes are -1).
+func (c *MemcachedCache) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
@@ -2,6 +2,7 @@ package memcached
 
 import (
 	"Go-library/cache"
+	"Go-library/cache/cache/codec"
 	"Go-library/cache/cache/compliance"
 	"testing"
 	"time"
@@ -27,3 +28,77 @@ func TestCompliance(t *testing.T) {
 		}
 	})
 }
+
+// non-string values go through the codec, so the suite must pass for each one
+func TestComplianceCodecs(t *testing.T) {
+	client := memcache.New("localhost:11211")
+	if err := client.Ping(); err != nil {
+		t.Skip("Memcached is not running on localhost:11211, skipping codec tests")
+	}
+
+	for _, cd := range []codec.Codec{codec.JSON, codec.Gob, codec.Msgpack} {
+		t.Run(cd.Name(), func(t *testing.T) {
+			compliance.RunTest(t, func(t *testing.T) (cache.Cache, func(time.Duration)) {
+				c := New(client)
+				c.SetCodec(cd)
+				_ = c.Clear()
+				return c, func(d time.Duration) {
+					time.Sleep(d)
+				}
+			})
+		})
+	}
+}
+
+func TestComplianceNamespace(t *testing.T) {
+	client := memcache.New("localhost:11211")
+	if err := client.Ping(); err != nil {
+		t.Skip("Memcached is not running on localhost:11211, skipping namespace tests")
+	}
+
+	compliance.RunTest(t, func(t *testing.T) (cache.Cache, func(time.Duration)) {
+		c := New(client)
+		c.SetNamespace("svc")
+		_ = c.Clear()
+		return c, func(d time.Duration) {
+			time.Sleep(d)
+		}
+	})
+}
+
+// Clear only empties its own namespace
+func TestNamespaceClear(t *testing.T) {
+	client := memcache.New("localhost:11211")
+	if err := client.Ping(); err != nil {
+		t.Skip("Memcached is not running on localhost:11211, skipping namespace tests")
+	}
+
+	a, b, plain := New(client), New(client), New(client)
+	a.SetNamespace("a")
+	b.SetNamespace("b")
+	plain.Set("k", "plain")
+	a.Set("k", "a")
+	a.SetWithTags("tagged", "a", 0, "t")
+	b.Set("k", "b")
+
+	if err := a.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	for _, key := range []string{"k", "tagged"} {
+		if _, err := a.Get(key); err != cache.ErrKeyNotFound {
+			t.Errorf("Expected a:%s cleared, got %v", key, err)
+		}
+	}
+	if val, _ := b.Get("k"); val != "b" {
+		t.Errorf("Expected b:k to survive, got %v", val)
+	}
+	if val, _ := plain.Get("k"); val != "plain" {
+		t.Errorf("Expected plain k to survive, got %v", val)
+	}
+
+	// a is usable again after the clear
+	a.Set("k", "a2")
+	if val, _ := a.Get("k"); val != "a2" {
+		t.Errorf("Expected 'a2', got %v", val)
+	}
+}
@@ -0,0 +1,46 @@
+package memcached
+
+import (
+	"errors"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// memcached cannot list or delete keys by prefix, so a namespace is
+// versioned instead. keys are stored as "<namespace>:<version>:<key>" and
+// Clear writes a new version, which leaves the old keys unreachable until
+// memcached evicts them.
+
+// nsVersionKey follows the namespace in the key holding its version.
+const nsVersionKey = ":__ns"
+
+// SetNamespace prefixes every key with the namespace and limits Clear to
+// it, so servers can be shared. every call then costs an extra round trip
+// to read the namespace version. "" restores the plain keys and a Clear
+// that flushes the whole server. call it before the cache is used.
+func (c *MemcachedCache) SetNamespace(ns string) {
+	c.namespace = ns
+}
+
+// prefix returns what goes in front of the cache keys, reading the current
+// namespace version.
+func (c *MemcachedCache) prefix() (string, error) {
+	if c.namespace == "" {
+		return "", nil
+	}
+	vkey := c.namespace + nsVersionKey
+	item, err := c.client.Get(vkey)
+	var version string
+	switch {
+	case err == nil:
+		version = string(item.Value)
+	case errors.Is(err, memcache.ErrCacheMiss):
+		// new, or its version was evicted, which also empties it
+		if version, err = c.startGeneration(vkey); err != nil {
+			return "", err
+		}
+	default:
+		return "", err
+	}
+	return c.namespace + ":" + version + ":", nil
+}
@@ -0,0 +1,199 @@
+package memcached
+
+import (
+	"Go-library/cache"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// memcached has no sets, so tags use generations instead. every tag has a
+// generation key holding a random token. a tagged item is stored in an
+// envelope with the tokens its tags had when it was written, and reads as
+// missing once any of them has changed. InvalidateTag only writes a new
+// token, the stale items are left for memcached to evict.
+
+// tagPrefix marks the generation keys, keys starting with it are reserved.
+const tagPrefix = "__tag:"
+
+// flagTagged is set in Item.Flags on items stored in a tagEnvelope.
+const flagTagged uint32 = 1
+
+type tagEnvelope struct {
+	Gens  map[string]string `json:"g"`
+	Value []byte            `json:"v"`
+}
+
+var _ cache.TagCache = (*MemcachedCache)(nil)
+
+// SetWithTags stores value tagged with the current generation of each tag.
+// a ttl of 0 means the key never expires. a later write without tags drops
+// them.
+func (c *MemcachedCache) SetWithTags(key string, value interface{}, ttl time.Duration, tags ...string) error {
+	if key == "" {
+		return cache.ErrEmptyKey
+	}
+	for _, tag := range tags {
+		if tag == "" {
+			return cache.ErrEmptyTag
+		}
+	}
+	if len(tags) == 0 {
+		return c.SetWithTTL(key, value, ttl)
+	}
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	prefix, err := c.prefix()
+	if err != nil {
+		return err
+	}
+	gens, err := c.generations(prefix, tags)
+	if err != nil {
+		return err
+	}
+	env, err := json.Marshal(tagEnvelope{Gens: gens, Value: data})
+	if err != nil {
+		return err
+	}
+	item := &memcache.Item{
+		Key:        prefix + key,
+		Value:      env,
+		Flags:      flagTagged,
+		Expiration: expiration(ttl),
+	}
+	if err := c.client.Set(item); err != nil {
+		return err
+	}
+	c.stats.AddSets(1)
+	return nil
+}
+
+// InvalidateTag moves the tag to a new generation, which hides every item
+// written under the old one.
+func (c *MemcachedCache) InvalidateTag(tag string) error {
+	if tag == "" {
+		return cache.ErrEmptyTag
+	}
+	prefix, err := c.prefix()
+	if err != nil {
+		return err
+	}
+	return c.client.Set(&memcache.Item{Key: prefix + tagPrefix + tag, Value: newGeneration()})
+}
+
+// generations returns the current generation of every tag, starting the
+// ones that have none yet.
+func (c *MemcachedCache) generations(prefix string, tags []string) (map[string]string, error) {
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = prefix + tagPrefix + tag
+	}
+	items, err := c.client.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+	gens := make(map[string]string, len(tags))
+	for i, tag := range tags {
+		if item, ok := items[keys[i]]; ok {
+			gens[tag] = string(item.Value)
+			continue
+		}
+		gen, err := c.startGeneration(keys[i])
+		if err != nil {
+			return nil, err
+		}
+		gens[tag] = gen
+	}
+	return gens, nil
+}
+
+// startGeneration stores a new generation under key unless one appeared
+// meanwhile, and returns the one that is there.
+func (c *MemcachedCache) startGeneration(key string) (string, error) {
+	gen := newGeneration()
+	err := c.client.Add(&memcache.Item{Key: key, Value: gen})
+	if errors.Is(err, memcache.ErrNotStored) {
+		// someone else started it first, use theirs
+		item, err := c.client.Get(key)
+		if err != nil {
+			return "", err
+		}
+		return string(item.Value), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(gen), nil
+}
+
+// decodeItems decodes items, leaving out tagged ones whose tags have been
+// invalidated since they were written. the generations of all tags are
+// fetched with a single request.
+func (c *MemcachedCache) decodeItems(prefix string, items map[string]*memcache.Item) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(items))
+	envs := make(map[string]*tagEnvelope)
+	var tagKeys []string
+	seen := make(map[string]bool)
+	for key, item := range items {
+		if item.Flags&flagTagged == 0 {
+			continue
+		}
+		env := &tagEnvelope{}
+		if err := json.Unmarshal(item.Value, env); err != nil {
+			return nil, err
+		}
+		envs[key] = env
+		for tag := range env.Gens {
+			if !seen[tag] {
+				seen[tag] = true
+				tagKeys = append(tagKeys, prefix+tagPrefix+tag)
+			}
+		}
+	}
+	var current map[string]*memcache.Item
+	if len(tagKeys) > 0 {
+		var err error
+		if current, err = c.client.GetMulti(tagKeys); err != nil {
+			return nil, err
+		}
+	}
+	for key, item := range items {
+		data := item.Value
+		if env, ok := envs[key]; ok {
+			if !fresh(prefix, env, current) {
+				continue
+			}
+			data = env.Value
+		}
+		var val interface{}
+		if err := c.codec.Unmarshal(data, &val); err != nil {
+			return nil, err
+		}
+		out[key] = val
+	}
+	return out, nil
+}
+
+// fresh reports whether every tag of env is still at the generation it was
+// written with. a generation memcached evicted counts as changed.
+func fresh(prefix string, env *tagEnvelope, current map[string]*memcache.Item) bool {
+	for tag, gen := range env.Gens {
+		item, ok := current[prefix+tagPrefix+tag]
+		if !ok || string(item.Value) != gen {
+			return false
+		}
+	}
+	return true
+}
+
+func newGeneration() []byte {
+	var b [8]byte
+	rand.Read(b[:])
+	return []byte(hex.EncodeToString(b[:]))
+}
@@ -3,17 +3,27 @@ package memory
 import (
 	"Go-library/cache"
 	"Go-library/cache/cache/compliance"
+	"sync"
 	"testing"
 	"time"
 )
 
-// TestCompliance runs the shared test suite
+// TestCompliance runs the shared test suite, TTLs run on a fake clock
 func TestCompliance(t *testing.T) {
 	compliance.RunTest(t, func(t *testing.T) (cache.Cache, func(time.Duration)) {
-		return NewMemorycache(), nil
+		c, clock := newClockedCache()
+		return c, clock.Advance
 	})
 }
 
+// newClockedCache returns a cache whose time only moves with clock.Advance.
+func newClockedCache() (*Memorycache, *cache.FakeClock) {
+	clock := cache.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := NewMemorycache()
+	c.SetClock(clock)
+	return c, clock
+}
+
 //some extra test which is not part of the standard function (set,get,delete,cleat)
 //since these are automatically applied in the redis and memcache this test specifically for the in-memory cache logic
 
@@ -117,12 +127,12 @@ func TestLRUUpdateOrder(t *testing.T) {
 
 // TestTTLWithLRU - testing interaction between TTL and LRU (implementation specific)
 func TestTTLWithLRU(t *testing.T) {
-	c := NewMemorycache()
+	c, clock := newClockedCache()
 	c.SetMaxSize(2)
 	c.SetWithTTL("a", 1, 50*time.Millisecond)
 	c.SetWithTTL("b", 2, 150*time.Millisecond)
 	//order [b,a]
-	time.Sleep(100 * time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
 	c.Set("c", 3)
 	//should evict a since it is expired anyway
 	_, err := c.Get("a")
@@ -136,3 +146,238 @@ func TestTTLWithLRU(t *testing.T) {
 		t.Fatalf("expected c to be exist,got %v", err3)
 	}
 }
+
+// expired entries must go away without ever being read again
+func TestJanitorPurgesExpired(t *testing.T) {
+	c := NewMemorycache()
+	c.StartJanitor(10 * time.Millisecond)
+	defer c.Close()
+
+	c.SetWithTTL("short", 1, 20*time.Millisecond)
+	c.Set("forever", 2)
+
+	time.Sleep(100 * time.Millisecond)
+
+	c.mu.Lock()
+	_, shortLeft := c.data["short"]
+	size, pending := len(c.data), len(c.expiries)
+	c.mu.Unlock()
+	if shortLeft || size != 1 || pending != 0 {
+		t.Fatalf("Expected only 'forever' to remain, got %d entries (%d with ttl)", size, pending)
+	}
+	if _, err := c.Get("forever"); err != nil {
+		t.Fatalf("Expected 'forever' to exist, got %v", err)
+	}
+}
+
+// only due entries are removed, in expiry order
+func TestDeleteExpired(t *testing.T) {
+	c, clock := newClockedCache()
+	c.SetWithTTL("a", 1, 10*time.Millisecond)
+	c.SetWithTTL("b", 2, time.Hour)
+	c.SetWithTTL("c", 3, 5*time.Millisecond)
+	// refreshing pushes 'a' behind 'b'
+	c.SetWithTTL("a", 1, 2*time.Hour)
+	c.Delete("b")
+
+	clock.Advance(20 * time.Millisecond)
+	c.DeleteExpired()
+
+	if len(c.data) != 1 || len(c.expiries) != 1 || c.expiries[0].key != "a" {
+		t.Fatalf("Expected only 'a' to remain, got %d entries", len(c.data))
+	}
+	for i, e := range c.expiries {
+		if e.index != i {
+			t.Fatalf("heap index out of sync for %q: %d != %d", e.key, e.index, i)
+		}
+	}
+}
+
+func TestCloseStopsJanitor(t *testing.T) {
+	c := NewMemorycache()
+	c.StartJanitor(5 * time.Millisecond)
+	c.Close()
+	// closing twice is fine
+	c.Close()
+
+	c.SetWithTTL("k", 1, time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	c.mu.Lock()
+	_, left := c.data["k"]
+	c.mu.Unlock()
+	if !left {
+		t.Fatalf("Expected no purge after Close")
+	}
+}
+
+// concurrent restarts leave a single janitor, Close stops it
+func TestStartJanitorConcurrent(t *testing.T) {
+	c := NewMemorycache()
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.StartJanitor(time.Minute)
+		}()
+	}
+	wg.Wait()
+	c.janitorMu.Lock()
+	done := c.janitorDone
+	c.janitorMu.Unlock()
+	if done == nil {
+		t.Fatal("Expected a running janitor")
+	}
+	c.Close()
+	select {
+	case <-done:
+	default:
+		t.Error("Expected the janitor to have stopped after Close")
+	}
+	c.janitorMu.Lock()
+	defer c.janitorMu.Unlock()
+	if c.stopJanitor != nil || c.janitorDone != nil {
+		t.Error("Expected no janitor left after Close")
+	}
+}
+
+// entries are evicted in LRU order until the byte budget holds
+func TestCostEviction(t *testing.T) {
+	c := NewMemorycache()
+	c.SetMaxCost(100)
+
+	c.SetWithCost("a", "x", 0, 40)
+	c.SetWithCost("b", "y", 0, 40)
+	// touch 'a' so 'b' is the LRU entry
+	c.Get("a")
+	c.SetWithCost("c", "z", 0, 30)
+
+	if _, err := c.Get("b"); err != cache.ErrKeyNotFound {
+		t.Fatalf("Expected 'b' to be evicted, got %v", err)
+	}
+	if _, err := c.Get("a"); err != nil {
+		t.Fatalf("Expected 'a' to exist, got %v", err)
+	}
+	if got := c.Cost(); got != 70 {
+		t.Fatalf("Expected cost 70, got %d", got)
+	}
+
+	// updating an entry replaces its cost
+	c.SetWithCost("a", "x", 0, 10)
+	if got := c.Cost(); got != 40 {
+		t.Fatalf("Expected cost 40 after update, got %d", got)
+	}
+
+	if err := c.SetWithCost("huge", "x", 0, 101); err != ErrCostTooLarge {
+		t.Fatalf("Expected ErrCostTooLarge, got %v", err)
+	}
+	c.Delete("c")
+	if got := c.Cost(); got != 10 {
+		t.Fatalf("Expected cost 10 after delete, got %d", got)
+	}
+}
+
+// a ttl of 0 drops the expiry of an earlier write
+func TestSetWithCostClearsExpiry(t *testing.T) {
+	c, clock := newClockedCache()
+	c.SetWithCost("k", "old", time.Second, 1)
+	c.SetWithCost("k", "new", 0, 1)
+	clock.Advance(2 * time.Second)
+	if val, err := c.Get("k"); err != nil || val != "new" {
+		t.Fatalf("Expected 'new' to outlive the old ttl, got %v, %v", val, err)
+	}
+}
+
+func TestSizer(t *testing.T) {
+	c := NewMemorycache()
+	c.SetSizer(func(key string, value interface{}) int64 {
+		return int64(len(value.(string)))
+	})
+	c.SetMaxCost(10)
+
+	c.Set("a", "12345")
+	c.Set("b", "1234")
+	c.Set("c", "123")
+	// a(5)+b(4)+c(3) > 10, 'a' goes
+	if _, err := c.Get("a"); err != cache.ErrKeyNotFound {
+		t.Fatalf("Expected 'a' to be evicted, got %v", err)
+	}
+	if got := c.Cost(); got != 7 {
+		t.Fatalf("Expected cost 7, got %d", got)
+	}
+	if err := c.Set("big", "12345678901"); err != ErrCostTooLarge {
+		t.Fatalf("Expected ErrCostTooLarge, got %v", err)
+	}
+}
+
+// turning the budget on later costs what is already stored
+func TestSetMaxCostRecosts(t *testing.T) {
+	c := NewMemorycache()
+	c.Set("a", string(make([]byte, 60)))
+	c.Set("b", string(make([]byte, 60)))
+	if c.Cost() != 0 {
+		t.Fatalf("Expected no cost tracking without a budget")
+	}
+	c.SetMaxCost(100)
+	if _, err := c.Get("a"); err != cache.ErrKeyNotFound {
+		t.Fatalf("Expected 'a' to be evicted, got %v", err)
+	}
+	if got := c.Cost(); got != 61 {
+		t.Fatalf("Expected cost 61, got %d", got)
+	}
+}
+
+func TestApproxSize(t *testing.T) {
+	if got := ApproxSize("hello"); got != 5 {
+		t.Errorf("Expected 5 for string, got %d", got)
+	}
+	if got := ApproxSize(make([]byte, 2048)); got != 2048 {
+		t.Errorf("Expected 2048 for []byte, got %d", got)
+	}
+	type node struct {
+		Name string
+		Next *node
+	}
+	n := &node{Name: "a"}
+	n.Next = n // cycles must terminate
+	if got := ApproxSize(n); got <= 0 {
+		t.Errorf("Expected positive size for struct, got %d", got)
+	}
+	m := map[string]interface{}{"k": "v"}
+	m["self"] = m
+	if got := ApproxSize(m); got <= 0 {
+		t.Errorf("Expected positive size for a map containing itself, got %d", got)
+	}
+	if small, big := ApproxSize([]string{"a"}), ApproxSize([]string{"a", "bbbbbbbbbb"}); big <= small {
+		t.Errorf("Expected bigger slice to cost more, got %d <= %d", big, small)
+	}
+}
+
+func TestStats(t *testing.T) {
+	c, clock := newClockedCache()
+	c.SetMaxSize(2)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")       // hit
+	c.Get("missing") // miss
+	c.Set("c", 3)    // evicts 'b'
+	c.Delete("c")
+	c.SetWithTTL("t", 4, time.Millisecond)
+	clock.Advance(5 * time.Millisecond)
+	c.Get("t") // expired, counts as a miss
+
+	s := c.Stats()
+	want := cache.Stats{Hits: 1, Misses: 2, Sets: 4, Deletes: 1, Evictions: 1, Expirations: 1, Items: 1, Bytes: -1}
+	if s != want {
+		t.Fatalf("Expected %+v, got %+v", want, s)
+	}
+	if r := s.HitRatio(); r < 0.33 || r > 0.34 {
+		t.Errorf("Expected hit ratio 1/3, got %f", r)
+	}
+
+	c.SetMaxCost(1 << 20)
+	if s := c.Stats(); s.Bytes <= 0 {
+		t.Errorf("Expected bytes to be known with a cost budget, got %d", s.Bytes)
+	}
+}
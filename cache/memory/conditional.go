@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"Go-library/cache"
+	"reflect"
+	"time"
+)
+
+var _ cache.ConditionalCache = (*Memorycache)(nil)
+
+// Add stores value only if key is missing or expired.
+func (c *Memorycache) Add(key string, value interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock()
+	if key == "" {
+		return cache.ErrEmptyKey
+	}
+	if _, ok := c.live(key); ok {
+		return cache.ErrKeyExists
+	}
+	return c.store(key, value, ttl)
+}
+
+// Replace stores value only if key is live.
+func (c *Memorycache) Replace(key string, value interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock()
+	if key == "" {
+		return cache.ErrEmptyKey
+	}
+	if _, ok := c.live(key); !ok {
+		return cache.ErrKeyNotFound
+	}
+	return c.store(key, value, ttl)
+}
+
+// CompareAndSwap stores new if key holds a value reflect.DeepEqual to old.
+func (c *Memorycache) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.unlock()
+	if key == "" {
+		return false, cache.ErrEmptyKey
+	}
+	e, ok := c.live(key)
+	if !ok {
+		return false, cache.ErrKeyNotFound
+	}
+	if !reflect.DeepEqual(e.value, old) {
+		return false, nil
+	}
+	if err := c.store(key, new, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// helpers below expect the caller to hold mu.
+
+// live returns key's entry, dropping it if it has expired. unlike get it
+// does not count a hit or miss.
+func (c *Memorycache) live(key string) (*entry, bool) {
+	e, ok := c.data[key]
+	if !ok {
+		return nil, false
+	}
+	if !e.expiresAt.IsZero() && c.clock.Now().After(e.expiresAt) {
+		c.removeEntry(e, EvictionExpired)
+		return nil, false
+	}
+	return e, true
+}
+
+// store writes value with exactly ttl, 0 clears any expiry.
+func (c *Memorycache) store(key string, value interface{}, ttl time.Duration) error {
+	cost := c.costOf(key, value)
+	if c.maxCost > 0 && cost > c.maxCost {
+		return ErrCostTooLarge
+	}
+	e := c.upsert(key, value, cost)
+	if ttl > 0 {
+		c.setExpiry(e, c.clock.Now().Add(ttl))
+	} else {
+		c.clearExpiry(e)
+	}
+	c.evict()
+	return nil
+}
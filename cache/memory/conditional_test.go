@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplaceClearsExpiry(t *testing.T) {
+	c, clock := newClockedCache()
+	c.SetWithTTL("k", "v", 10*time.Millisecond)
+	if err := c.Replace("k", "w", 0); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	clock.Advance(20 * time.Millisecond)
+	c.DeleteExpired()
+	if val, err := c.Get("k"); err != nil || val != "w" {
+		t.Errorf("Expected 'w' without expiry, got %v, %v", val, err)
+	}
+}
+
+func TestCompareAndSwapDeepEqual(t *testing.T) {
+	c := NewMemorycache()
+	c.Set("k", []string{"a", "b"})
+	ok, err := c.CompareAndSwap("k", []string{"a", "b"}, []string{"c"}, 0)
+	if !ok || err != nil {
+		t.Fatalf("Expected a swap for an equal slice, got %v, %v", ok, err)
+	}
+	if ok, _ := c.CompareAndSwap("k", []string{"a", "b"}, []string{"d"}, 0); ok {
+		t.Errorf("Expected no swap after the value changed")
+	}
+}
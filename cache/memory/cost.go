@@ -0,0 +1,171 @@
+package memory
+
+import (
+	"Go-library/cache"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// ErrCostTooLarge is returned when a single value costs more than the whole
+// budget set with SetMaxCost, it could never be kept.
+var ErrCostTooLarge = errors.New("value cost exceeds the cache budget")
+
+// Sizer computes the cost of an entry, usually its size in bytes.
+type Sizer func(key string, value interface{}) int64
+
+// DefaultSizer charges the length of the key plus ApproxSize of the value.
+func DefaultSizer(key string, value interface{}) int64 {
+	return int64(len(key)) + ApproxSize(value)
+}
+
+// SetMaxCost caps the total cost of all entries, 0 means no limit. it can be
+// combined with SetMaxSize, LRU entries are evicted until both hold.
+// entries stored before the budget was enabled are costed on the way in.
+func (c *Memorycache) SetMaxCost(maxCost int64) {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.maxCost == 0 && maxCost > 0 {
+		c.maxCost = maxCost
+		c.recost()
+	}
+	c.maxCost = maxCost
+	c.evict()
+}
+
+// SetSizer replaces DefaultSizer for values stored without an explicit cost.
+// existing entries keep the cost they were stored with.
+func (c *Memorycache) SetSizer(s Sizer) {
+	c.mu.Lock()
+	defer c.unlock()
+	c.sizer = s
+}
+
+// SetWithCost adds or updates a value with a caller supplied cost instead of
+// asking the Sizer. a ttl of 0 means the value never expires, also when it
+// had an expiry before.
+func (c *Memorycache) SetWithCost(key string, value interface{}, ttl time.Duration, cost int64) error {
+	c.mu.Lock()
+	defer c.unlock()
+	if key == "" {
+		return cache.ErrEmptyKey
+	}
+	if c.maxCost > 0 && cost > c.maxCost {
+		return ErrCostTooLarge
+	}
+	e := c.upsert(key, value, cost)
+	e.fixedCost = true
+	if ttl > 0 {
+		c.setExpiry(e, c.clock.Now().Add(ttl))
+	} else {
+		c.clearExpiry(e)
+	}
+	c.evict()
+	return nil
+}
+
+// Cost returns the total cost of the entries currently held.
+func (c *Memorycache) Cost() int64 {
+	c.mu.Lock()
+	defer c.unlock()
+	return c.cost
+}
+
+// costOf asks the sizer for the cost of a value. costs are only tracked once
+// a budget or a sizer is configured, the caller holds mu.
+func (c *Memorycache) costOf(key string, value interface{}) int64 {
+	switch {
+	case c.sizer != nil:
+		return c.sizer(key, value)
+	case c.maxCost > 0:
+		return DefaultSizer(key, value)
+	}
+	return 0
+}
+
+// recost recomputes the cost of entries without an explicit one. o(n)
+func (c *Memorycache) recost() {
+	c.cost = 0
+	for _, e := range c.data {
+		if !e.fixedCost {
+			e.cost = c.costOf(e.key, e.value)
+		}
+		c.cost += e.cost
+	}
+}
+
+// ApproxSize estimates how many bytes a value occupies. strings and byte
+// slices count their length, other values are walked with reflection.
+// shared pointers, slices and maps are only counted once.
+func ApproxSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	}
+	return approxSize(reflect.ValueOf(value), make(map[uintptr]bool))
+}
+
+func approxSize(v reflect.Value, seen map[uintptr]bool) int64 {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.String:
+		return int64(v.Type().Size()) + int64(v.Len())
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return int64(v.Type().Size())
+		}
+		if v.Kind() == reflect.Pointer {
+			if seen[v.Pointer()] {
+				return int64(v.Type().Size())
+			}
+			seen[v.Pointer()] = true
+		}
+		return int64(v.Type().Size()) + approxSize(v.Elem(), seen)
+	case reflect.Slice:
+		size := int64(v.Type().Size())
+		if v.IsNil() {
+			return size
+		}
+		if seen[v.Pointer()] {
+			return size
+		}
+		seen[v.Pointer()] = true
+		for i := 0; i < v.Len(); i++ {
+			size += approxSize(v.Index(i), seen)
+		}
+		// unused capacity still takes memory
+		return size + int64(v.Cap()-v.Len())*int64(v.Type().Elem().Size())
+	case reflect.Array:
+		var size int64
+		for i := 0; i < v.Len(); i++ {
+			size += approxSize(v.Index(i), seen)
+		}
+		return size
+	case reflect.Map:
+		size := int64(v.Type().Size())
+		if v.IsNil() {
+			return size
+		}
+		if seen[v.Pointer()] {
+			return size
+		}
+		seen[v.Pointer()] = true
+		iter := v.MapRange()
+		for iter.Next() {
+			size += approxSize(iter.Key(), seen) + approxSize(iter.Value(), seen)
+		}
+		return size
+	case reflect.Struct:
+		var size int64
+		for i := 0; i < v.NumField(); i++ {
+			size += approxSize(v.Field(i), seen)
+		}
+		return size
+	}
+	return int64(v.Type().Size())
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"Go-library/cache"
+	"time"
+)
+
+var _ cache.CounterCache = (*Memorycache)(nil)
+
+// Incr adds delta to the int64 under key, an int is taken as well. the
+// result is stored as an int64. like any write it drops the key's tags.
+func (c *Memorycache) Incr(key string, delta int64) (int64, error) {
+	return c.IncrWithTTL(key, delta, 0)
+}
+
+// Decr subtracts delta from the int64 under key, see Incr.
+func (c *Memorycache) Decr(key string, delta int64) (int64, error) {
+	return c.IncrWithTTL(key, -delta, 0)
+}
+
+// IncrWithTTL is Incr that expires a key it creates after ttl.
+func (c *Memorycache) IncrWithTTL(key string, delta int64, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.unlock()
+	if key == "" {
+		return 0, cache.ErrEmptyKey
+	}
+	var n int64
+	e, ok := c.live(key)
+	if ok {
+		switch v := e.value.(type) {
+		case int64:
+			n = v
+		case int:
+			n = int64(v)
+		default:
+			return 0, cache.ErrNotInteger
+		}
+	}
+	n += delta
+	cost := c.costOf(key, n)
+	if c.maxCost > 0 && cost > c.maxCost {
+		return 0, ErrCostTooLarge
+	}
+	e = c.upsert(key, n, cost)
+	if !ok && ttl > 0 {
+		c.setExpiry(e, c.clock.Now().Add(ttl))
+	}
+	c.evict()
+	return n, nil
+}
+
+// DecrWithTTL is Decr that expires a key it creates after ttl.
+func (c *Memorycache) DecrWithTTL(key string, delta int64, ttl time.Duration) (int64, error) {
+	return c.IncrWithTTL(key, -delta, ttl)
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"Go-library/cache"
+	"testing"
+	"time"
+)
+
+func TestIncrTakesInts(t *testing.T) {
+	c := NewMemorycache()
+	c.Set("n", 41)
+	if n, err := c.Incr("n", 1); err != nil || n != 42 {
+		t.Fatalf("Expected 42, got %d, %v", n, err)
+	}
+	if val, _ := c.Get("n"); val != int64(42) {
+		t.Errorf("Expected int64(42) stored, got %T %v", val, val)
+	}
+	if n, err := c.Decr("n", 50); err != nil || n != -8 {
+		t.Errorf("Expected -8, got %d, %v", n, err)
+	}
+	c.Set("f", 1.5)
+	if _, err := c.Incr("f", 1); err != cache.ErrNotInteger {
+		t.Errorf("Expected ErrNotInteger for a float, got %v", err)
+	}
+}
+
+func TestIncrKeepsExpiry(t *testing.T) {
+	c, clock := newClockedCache()
+	c.SetWithTTL("n", int64(1), time.Hour)
+	if _, err := c.IncrWithTTL("n", 1, time.Millisecond); err != nil {
+		t.Fatalf("IncrWithTTL failed: %v", err)
+	}
+	clock.Advance(5 * time.Millisecond)
+	if val, err := c.Get("n"); err != nil || val != int64(2) {
+		t.Errorf("Expected the hour ttl to be kept, got %v, %v", val, err)
+	}
+}
@@ -0,0 +1,59 @@
+package memory
+
+import "fmt"
+
+// EvictionReason says why an entry left the cache.
+type EvictionReason int
+
+const (
+	// pushed out by the size or cost limit
+	EvictionCapacity EvictionReason = iota + 1
+	// found past its TTL by Get or the janitor
+	EvictionExpired
+	// removed by Delete or DeleteMulti
+	EvictionDeleted
+	// removed by Clear
+	EvictionCleared
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionCapacity:
+		return "capacity"
+	case EvictionExpired:
+		return "expired"
+	case EvictionDeleted:
+		return "deleted"
+	case EvictionCleared:
+		return "cleared"
+	}
+	return fmt.Sprintf("EvictionReason(%d)", int(r))
+}
+
+// an eviction waiting for the lock to be released
+type evicted struct {
+	key    string
+	value  interface{}
+	reason EvictionReason
+}
+
+// OnEvict registers fn to be called for every entry that leaves the cache,
+// nil removes it. fn runs after the cache lock is released, in the goroutine
+// that caused the eviction, so it may call back into the cache. overwriting
+// a key with Set does not count as an eviction.
+func (c *Memorycache) OnEvict(fn func(key string, value interface{}, reason EvictionReason)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = fn
+}
+
+// unlock releases mu and only then runs the callbacks for what was evicted
+// while it was held.
+func (c *Memorycache) unlock() {
+	pending, fn := c.pending, c.onEvict
+	c.pending = nil
+	c.mu.Unlock()
+	for _, ev := range pending {
+		fn(ev.key, ev.value, ev.reason)
+	}
+}
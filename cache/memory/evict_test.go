@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type evictLog struct {
+	mu     sync.Mutex
+	events map[string]EvictionReason
+}
+
+func watch(c *Memorycache) *evictLog {
+	l := &evictLog{events: make(map[string]EvictionReason)}
+	c.OnEvict(func(key string, value interface{}, reason EvictionReason) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.events[key] = reason
+	})
+	return l
+}
+
+func (l *evictLog) reason(key string) EvictionReason {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.events[key]
+}
+
+func TestOnEvictReasons(t *testing.T) {
+	c := NewMemorycache()
+	log := watch(c)
+	c.SetMaxSize(2)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3) // pushes out 'a'
+	if got := log.reason("a"); got != EvictionCapacity {
+		t.Errorf("Expected 'a' evicted for capacity, got %v", got)
+	}
+
+	c.Delete("b")
+	if got := log.reason("b"); got != EvictionDeleted {
+		t.Errorf("Expected 'b' deleted, got %v", got)
+	}
+
+	c.SetWithTTL("ttl", 4, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	c.Get("ttl")
+	if got := log.reason("ttl"); got != EvictionExpired {
+		t.Errorf("Expected 'ttl' expired, got %v", got)
+	}
+
+	c.Clear()
+	if got := log.reason("c"); got != EvictionCleared {
+		t.Errorf("Expected 'c' cleared, got %v", got)
+	}
+
+	// overwriting is not an eviction
+	c.Set("d", 1)
+	c.Set("d", 2)
+	if got := log.reason("d"); got != 0 {
+		t.Errorf("Expected no callback for overwrite, got %v", got)
+	}
+}
+
+func TestOnEvictJanitor(t *testing.T) {
+	c := NewMemorycache()
+	log := watch(c)
+	c.SetWithTTL("k", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	c.DeleteExpired()
+	if got := log.reason("k"); got != EvictionExpired {
+		t.Errorf("Expected 'k' expired, got %v", got)
+	}
+}
+
+// the callback runs without the lock, so it may use the cache itself
+func TestOnEvictOutsideLock(t *testing.T) {
+	c := NewMemorycache()
+	c.SetMaxSize(1)
+	var got interface{}
+	c.OnEvict(func(key string, value interface{}, reason EvictionReason) {
+		if key == "a" {
+			c.Set("graveyard", value)
+			got, _ = c.Get("graveyard")
+		}
+	})
+	done := make(chan struct{})
+	go func() {
+		c.Set("a", 1)
+		c.Set("b", 2)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("callback deadlocked on the cache lock")
+	}
+	if got != 1 {
+		t.Errorf("Expected callback to read back 1, got %v", got)
+	}
+}
+
+func TestEvictionReasonString(t *testing.T) {
+	if EvictionCapacity.String() != "capacity" || EvictionReason(99).String() != "EvictionReason(99)" {
+		t.Errorf("unexpected EvictionReason strings")
+	}
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiryHeap orders the entries that have a TTL by expiry time, soonest first,
+// so the janitor only looks at entries that are actually due.
+type expiryHeap []*entry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
+// setExpiry updates e's expiry and its place in the heap, the caller holds mu.
+func (c *Memorycache) setExpiry(e *entry, expiresAt time.Time) {
+	e.expiresAt = expiresAt
+	if e.index >= 0 {
+		heap.Fix(&c.expiries, e.index)
+		return
+	}
+	heap.Push(&c.expiries, e)
+}
+
+// clearExpiry makes e live until it is removed, the caller holds mu.
+func (c *Memorycache) clearExpiry(e *entry) {
+	e.expiresAt = time.Time{}
+	if e.index >= 0 {
+		heap.Remove(&c.expiries, e.index)
+	}
+}
+
+// StartJanitor starts a goroutine that purges expired entries every interval.
+// without it expired entries are only dropped when they are read or evicted.
+// calling it again restarts the janitor with the new interval, Close stops it.
+func (c *Memorycache) StartJanitor(interval time.Duration) {
+	// one critical section, so concurrent calls cannot both start one
+	c.janitorMu.Lock()
+	defer c.janitorMu.Unlock()
+	c.stopJanitorLocked()
+	if interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	c.stopJanitor, c.janitorDone = stop, done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.DeleteExpired()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the janitor if one is running. the cache stays usable.
+func (c *Memorycache) Close() error {
+	c.janitorMu.Lock()
+	defer c.janitorMu.Unlock()
+	c.stopJanitorLocked()
+	return nil
+}
+
+// stopJanitorLocked stops the janitor and waits for it, the caller holds
+// janitorMu.
+func (c *Memorycache) stopJanitorLocked() {
+	if c.stopJanitor == nil {
+		return
+	}
+	close(c.stopJanitor)
+	<-c.janitorDone
+	c.stopJanitor, c.janitorDone = nil, nil
+}
+
+// DeleteExpired removes every expired entry now. o(k log n) for k expired entries.
+func (c *Memorycache) DeleteExpired() {
+	c.mu.Lock()
+	defer c.unlock()
+	now := c.clock.Now()
+	for len(c.expiries) > 0 && now.After(c.expiries[0].expiresAt) {
+		c.removeEntry(c.expiries[0], EvictionExpired)
+	}
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"Go-library/cache"
+	"iter"
+	"strings"
+)
+
+var _ cache.IterableCache = (*Memorycache)(nil)
+var _ cache.IterableCache = (*ShardedCache)(nil)
+
+// Keys yields the live keys starting with prefix. they are copied under the
+// lock first, so the loop body may use the cache, and keys changed meanwhile
+// are not seen. listing does not count as use for eviction or stats. o(n)
+func (c *Memorycache) Keys(prefix string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, e := range c.snapshot(prefix) {
+			if !yield(e.key) {
+				return
+			}
+		}
+	}
+}
+
+// Range yields the live keys starting with prefix with their values, from
+// a copy taken under the lock like Keys. o(n)
+func (c *Memorycache) Range(prefix string) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		for _, e := range c.snapshot(prefix) {
+			if !yield(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+type keyValue struct {
+	key   string
+	value interface{}
+}
+
+func (c *Memorycache) snapshot(prefix string) []keyValue {
+	c.mu.Lock()
+	defer c.unlock()
+	now := c.clock.Now()
+	out := make([]keyValue, 0, len(c.data))
+	for key, e := range c.data {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if !e.expiresAt.IsZero() && now.After(e.expiresAt) {
+			continue
+		}
+		out = append(out, keyValue{key, e.value})
+	}
+	return out
+}
+
+// Keys yields the live keys of every shard, one shard after another.
+func (s *ShardedCache) Keys(prefix string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, sh := range s.shards {
+			for key := range sh.Keys(prefix) {
+				if !yield(key) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Range yields the live entries of every shard, one shard after another.
+func (s *ShardedCache) Range(prefix string) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		for _, sh := range s.shards {
+			for key, value := range sh.Range(prefix) {
+				if !yield(key, value) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestKeysSkipsExpired(t *testing.T) {
+	c, clock := newClockedCache()
+	c.Set("live", 1)
+	c.SetWithTTL("gone", 2, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+	if keys := slices.Collect(c.Keys("")); !slices.Equal(keys, []string{"live"}) {
+		t.Errorf("Expected [live], got %v", keys)
+	}
+}
+
+// the loop body runs without the lock held
+func TestRangeCanUseCache(t *testing.T) {
+	c := NewMemorycache()
+	c.Set("a", 1)
+	c.Set("b", 2)
+	for key := range c.Range("") {
+		c.Delete(key)
+	}
+	if n := len(c.data); n != 0 {
+		t.Errorf("Expected an empty cache, got %d items", n)
+	}
+}
+
+func TestShardedKeys(t *testing.T) {
+	s := NewSharded(4)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		s.Set(key, key)
+	}
+	keys := slices.Sorted(s.Keys(""))
+	if !slices.Equal(keys, []string{"a", "b", "c", "d", "e"}) {
+		t.Errorf("Expected every key once, got %v", keys)
+	}
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+// locks of lock.Acquire are kept apart from the entries, so they are never
+// evicted and survive Clear. they only work within the process, which is
+// what tests need.
+
+type heldLock struct {
+	owner     string
+	expiresAt time.Time
+}
+
+// TryLock takes the lock name for owner unless it is held, and returns a
+// fencing token larger than any given out before for name.
+func (c *Memorycache) TryLock(ctx context.Context, name, owner string, ttl time.Duration) (int64, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if _, ok := c.heldLock(name); ok {
+		return 0, false, nil
+	}
+	if c.locks == nil {
+		c.locks = make(map[string]heldLock)
+		c.fences = make(map[string]int64)
+	}
+	c.locks[name] = heldLock{owner: owner, expiresAt: c.clock.Now().Add(ttl)}
+	c.fences[name]++
+	return c.fences[name], true, nil
+}
+
+// RefreshLock gives the lock a new ttl if owner still holds it.
+func (c *Memorycache) RefreshLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	l, ok := c.heldLock(name)
+	if !ok || l.owner != owner {
+		return false, nil
+	}
+	l.expiresAt = c.clock.Now().Add(ttl)
+	c.locks[name] = l
+	return true, nil
+}
+
+// Unlock releases the lock if owner still holds it.
+func (c *Memorycache) Unlock(ctx context.Context, name, owner string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	l, ok := c.heldLock(name)
+	if !ok || l.owner != owner {
+		return false, nil
+	}
+	delete(c.locks, name)
+	return true, nil
+}
+
+// heldLock returns the lock unless it is free or expired, the caller holds mu.
+func (c *Memorycache) heldLock(name string) (heldLock, bool) {
+	l, ok := c.locks[name]
+	if !ok {
+		return heldLock{}, false
+	}
+	if c.clock.Now().After(l.expiresAt) {
+		delete(c.locks, name)
+		return heldLock{}, false
+	}
+	return l, true
+}
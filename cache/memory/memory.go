@@ -2,69 +2,141 @@ package memory
 
 import (
 	"Go-library/cache"
-	"container/list"
+	"container/heap"
+	"context"
 	"sync"
 	"time"
 )
 
-// in-memory cache with LRU eviction.
+// in-memory cache with LRU eviction by default, see SetPolicy.
 type Memorycache struct {
-	maxSize int
-	ll      *list.List
-	data    map[string]*list.Element
-	mu      sync.Mutex
+	maxSize  int
+	policy   Policy
+	data     map[string]*entry
+	expiries expiryHeap
+	mu       sync.Mutex
+
+	// cost based capacity, see SetMaxCost
+	maxCost int64
+	cost    int64
+	sizer   Sizer
+
+	// eviction callback and the evictions it still has to hear about
+	onEvict func(key string, value interface{}, reason EvictionReason)
+	pending []evicted
+
+	stats cache.StatsRecorder
+	// source of the time for TTLs and locks, see SetClock
+	clock cache.Clock
+
+	// tag -> keys filed under it, see SetWithTags
+	tags map[string]map[string]struct{}
+
+	// locks of TryLock and their fencing counters, see lock.go
+	locks  map[string]heldLock
+	fences map[string]int64
+
+	// background purge of expired entries, see StartJanitor
+	janitorMu   sync.Mutex
+	stopJanitor chan struct{}
+	janitorDone chan struct{}
 }
 
 type entry struct {
 	key       string
 	value     interface{}
 	expiresAt time.Time
+	index     int // position in expiries, -1 without a TTL
+	cost      int64
+	fixedCost bool // cost given by the caller, not the Sizer
+	tags      []string
 }
 
 // New creates a new instance of Cache.
 func NewMemorycache() *Memorycache {
 	return &Memorycache{
 		maxSize: 0, // 0 means no limit
-		ll:      list.New(),
-		data:    make(map[string]*list.Element),
+		policy:  NewLRUPolicy(),
+		data:    make(map[string]*entry),
+		clock:   cache.SystemClock,
 	}
 }
 
 var _ cache.Cache = (*Memorycache)(nil)
+var _ cache.ContextCache = (*Memorycache)(nil)
+var _ cache.BatchCache = (*Memorycache)(nil)
+var _ cache.StatsProvider = (*Memorycache)(nil)
+var _ cache.CapabilityProvider = (*Memorycache)(nil)
+
+// Capabilities: everything but sharing, values are kept as they are.
+func (c *Memorycache) Capabilities() cache.Capabilities {
+	return cache.Capabilities{
+		AnyValue:    true,
+		Context:     true,
+		Batch:       true,
+		Stats:       true,
+		Tags:        true,
+		Counters:    true,
+		Conditional: true,
+		Iteration:   true,
+		ScopedClear: true,
+	}
+}
+
+// SetClock replaces the clock TTLs are measured with, nil restores
+// cache.SystemClock. a cache.FakeClock makes expiry testable without
+// sleeping. the janitor still runs on real time.
+func (c *Memorycache) SetClock(clock cache.Clock) {
+	c.mu.Lock()
+	defer c.unlock()
+	if clock == nil {
+		clock = cache.SystemClock
+	}
+	c.clock = clock
+}
 
 // change 1 addeds mutex
 // If valid is less than current size, eviction will happen.
 // only this is o(n) since for resizing i have to go on and evict in linear way
 func (c *Memorycache) SetMaxSize(size int) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	c.maxSize = size
-	if c.maxSize > 0 && c.ll.Len() > c.maxSize {
+	if c.maxSize > 0 && len(c.data) > c.maxSize {
 		c.evict()
 	}
 }
 
+// SetPolicy replaces the eviction policy, nil restores LRU. keys already
+// in the cache are handed to the new policy in no particular order. o(n)
+func (c *Memorycache) SetPolicy(p Policy) {
+	c.mu.Lock()
+	defer c.unlock()
+	if p == nil {
+		p = NewLRUPolicy()
+	}
+	p.Reset()
+	for key := range c.data {
+		p.Add(key)
+	}
+	c.policy = p
+	c.evict()
+}
+
 // change 1 addeds mutex
 // Set adds or updates a value in the cache. o(1)
 func (c *Memorycache) Set(key string, value interface{}) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	if key == "" {
 		return cache.ErrEmptyKey
 	}
-
-	if elem, ok := c.data[key]; ok {
-		c.ll.MoveToFront(elem)
-		elem.Value.(*entry).value = value
-		return nil
-	}
-
-	elem := c.ll.PushFront(&entry{key, value, time.Time{}})
-	c.data[key] = elem
-
-	if c.maxSize > 0 && c.ll.Len() > c.maxSize {
-		c.evict()
+	cost := c.costOf(key, value)
+	if c.maxCost > 0 && cost > c.maxCost {
+		return ErrCostTooLarge
 	}
+	c.upsert(key, value, cost)
+	c.evict()
 	return nil
 }
 
@@ -72,22 +144,16 @@ func (c *Memorycache) Set(key string, value interface{}) error {
 // have a seperate set with TTL,follows go-idiometic pattern
 func (c *Memorycache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	if key == "" {
 		return cache.ErrEmptyKey
 	}
-	if elem, ok := c.data[key]; ok {
-		c.ll.MoveToFront(elem)
-		elem.Value.(*entry).value = value
-		elem.Value.(*entry).expiresAt = time.Now().Add(ttl)
-		return nil
-	}
-	elem := c.ll.PushFront(&entry{key, value, time.Now().Add(ttl)})
-	c.data[key] = elem
-
-	if c.maxSize > 0 && c.ll.Len() > c.maxSize {
-		c.evict()
+	cost := c.costOf(key, value)
+	if c.maxCost > 0 && cost > c.maxCost {
+		return ErrCostTooLarge
 	}
+	c.setExpiry(c.upsert(key, value, cost), c.clock.Now().Add(ttl))
+	c.evict()
 	return nil
 }
 
@@ -95,21 +161,12 @@ func (c *Memorycache) SetWithTTL(key string, value interface{}, ttl time.Duratio
 // Get retrieves a value from the cache. o(1)
 func (c *Memorycache) Get(key string) (interface{}, error) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	if key == "" {
 		return nil, cache.ErrEmptyKey
 	}
-	if elem, ok := c.data[key]; ok {
-		//check if the key is exppired
-		if !elem.Value.(*entry).expiresAt.IsZero() && time.Now().After(elem.Value.(*entry).expiresAt) {
-			// c.Delete(key) //self dead loack happend because of nesterd calling
-			c.ll.Remove(elem)
-			delete(c.data, key)
-			// return nil,ErrKeyExpired //(for debugging key expired is not something to be exposed )
-			return nil, cache.ErrKeyNotFound
-		}
-		c.ll.MoveToFront(elem)
-		return elem.Value.(*entry).value, nil
+	if val, ok := c.get(key); ok {
+		return val, nil
 	}
 	return nil, cache.ErrKeyNotFound
 }
@@ -118,36 +175,236 @@ func (c *Memorycache) Get(key string) (interface{}, error) {
 // Delete removes a key from the cache.
 func (c *Memorycache) Delete(key string) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	if key == "" {
 		return cache.ErrEmptyKey
 	}
-	if elem, ok := c.data[key]; ok {
-		c.ll.Remove(elem)
-		delete(c.data, key)
+	if e, ok := c.data[key]; ok {
+		c.removeEntry(e, EvictionDeleted)
 		return nil
 	}
 	return cache.ErrKeyNotFound
 }
 
 // change 1 addeds mutex
-// Clear removes all keys from the cache.o(1)
+// Clear removes all keys from the cache.o(1), o(n) with an OnEvict callback
 func (c *Memorycache) Clear() error {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.onEvict != nil {
+		for _, e := range c.data {
+			c.pending = append(c.pending, evicted{e.key, e.value, EvictionCleared})
+		}
+	}
+	c.policy.Reset()
+	c.data = make(map[string]*entry)
+	c.tags = nil
+	c.expiries = nil
+	c.cost = 0
+	return nil
+}
+
+// batch operations take the lock once for the whole batch.
+
+// GetMulti returns the live values of keys, missing and expired keys are left out.
+func (c *Memorycache) GetMulti(keys []string) (map[string]interface{}, error) {
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	out := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		if val, ok := c.get(key); ok {
+			out[key] = val
+		}
+	}
+	return out, nil
+}
+
+// SetMulti adds or updates all items. ttl 0 means no expiry, also for keys
+// that had one.
+func (c *Memorycache) SetMulti(items map[string]interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock()
+	costs := make(map[string]int64, len(items))
+	for key, value := range items {
+		if key == "" {
+			return cache.ErrEmptyKey
+		}
+		costs[key] = c.costOf(key, value)
+		if c.maxCost > 0 && costs[key] > c.maxCost {
+			return ErrCostTooLarge
+		}
+	}
+	now := c.clock.Now()
+	for key, value := range items {
+		e := c.upsert(key, value, costs[key])
+		if ttl > 0 {
+			c.setExpiry(e, now.Add(ttl))
+		} else {
+			c.clearExpiry(e)
+		}
+	}
+	c.evict()
+	return nil
+}
+
+// DeleteMulti removes keys, keys that do not exist are ignored.
+func (c *Memorycache) DeleteMulti(keys []string) error {
+	if err := checkKeys(keys); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	for _, key := range keys {
+		if e, ok := c.data[key]; ok {
+			c.removeEntry(e, EvictionDeleted)
+		}
+	}
+	return nil
+}
+
+// Stats returns the counters since creation. Bytes is the total cost and
+// only known once a cost budget or a Sizer is set.
+func (c *Memorycache) Stats() cache.Stats {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.ll.Init()
-	c.data = make(map[string]*list.Element)
+	s := c.stats.Snapshot()
+	s.Items = int64(len(c.data))
+	if c.maxCost > 0 || c.sizer != nil {
+		s.Bytes = c.cost
+	}
+	return s
+}
+
+// context-aware variants. everything is in-process so the ctx is only
+// checked before taking the lock.
+
+// SetCtx is Set honouring ctx cancellation.
+func (c *Memorycache) SetCtx(ctx context.Context, key string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Set(key, value)
+}
+
+// SetWithTTLCtx is SetWithTTL honouring ctx cancellation.
+func (c *Memorycache) SetWithTTLCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+// GetCtx is Get honouring ctx cancellation.
+func (c *Memorycache) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Get(key)
+}
+
+// DeleteCtx is Delete honouring ctx cancellation.
+func (c *Memorycache) DeleteCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Delete(key)
+}
+
+// ClearCtx is Clear honouring ctx cancellation.
+func (c *Memorycache) ClearCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Clear()
+}
+
+// helpers below expect the caller to hold mu.
+
+// upsert inserts key or refreshes an existing one and returns its entry.
+// an existing entry keeps its expiry but loses its tags.
+func (c *Memorycache) upsert(key string, value interface{}, cost int64) *entry {
+	c.stats.AddSets(1)
+	if e, ok := c.data[key]; ok {
+		c.untag(e)
+		c.policy.Touch(key)
+		e.value = value
+		c.cost += cost - e.cost
+		e.cost, e.fixedCost = cost, false
+		return e
+	}
+	e := &entry{key: key, value: value, index: -1, cost: cost}
+	c.data[key] = e
+	c.policy.Add(key)
+	c.cost += cost
+	return e
+}
+
+// get returns a live value and marks it recently used, expired entries are
+// dropped on the way.
+func (c *Memorycache) get(key string) (interface{}, bool) {
+	e, ok := c.data[key]
+	if !ok {
+		c.stats.AddMisses(1)
+		return nil, false
+	}
+	//check if the key is exppired
+	if !e.expiresAt.IsZero() && c.clock.Now().After(e.expiresAt) {
+		// return nil,ErrKeyExpired //(for debugging key expired is not something to be exposed )
+		c.removeEntry(e, EvictionExpired)
+		c.stats.AddMisses(1)
+		return nil, false
+	}
+	c.stats.AddHits(1)
+	c.policy.Touch(key)
+	return e.value, true
+}
+
+func (c *Memorycache) removeEntry(e *entry, reason EvictionReason) {
+	if c.onEvict != nil {
+		c.pending = append(c.pending, evicted{e.key, e.value, reason})
+	}
+	switch reason {
+	case EvictionCapacity:
+		c.stats.AddEvictions(1)
+	case EvictionExpired:
+		c.stats.AddExpirations(1)
+	case EvictionDeleted:
+		c.stats.AddDeletes(1)
+	}
+	delete(c.data, e.key)
+	c.untag(e)
+	c.policy.Remove(e.key)
+	c.cost -= e.cost
+	if e.index >= 0 {
+		heap.Remove(&c.expiries, e.index)
+	}
+}
+
+func (c *Memorycache) overCapacity() bool {
+	return (c.maxSize > 0 && len(c.data) > c.maxSize) ||
+		(c.maxCost > 0 && c.cost > c.maxCost)
+}
+
+func checkKeys(keys []string) error {
+	for _, key := range keys {
+		if key == "" {
+			return cache.ErrEmptyKey
+		}
+	}
 	return nil
 }
 
 // helper function (no change required for TTL implementation)
+// drops the policy's victims until both the size and cost limits hold
 func (c *Memorycache) evict() {
-	for c.ll.Len() > c.maxSize {
-		elem := c.ll.Back()
-		if elem != nil {
-			c.ll.Remove(elem)
-			kv := elem.Value.(*entry)
-			delete(c.data, kv.key)
+	for c.overCapacity() {
+		key, ok := c.policy.Victim()
+		if !ok {
+			return
 		}
+		c.removeEntry(c.data[key], EvictionCapacity)
 	}
 }
@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// Policy decides which entry goes when the cache is over capacity.
+// the cache calls it with its lock held, so implementations need no locking
+// of their own.
+type Policy interface {
+	// Add records a key that was just inserted.
+	Add(key string)
+	// Touch records a read or an update of a tracked key.
+	Touch(key string)
+	// Remove forgets a key that left the cache for any reason.
+	Remove(key string)
+	// Victim names the key to evict next, false when nothing is tracked.
+	// the cache removes it, which in turn calls Remove.
+	Victim() (string, bool)
+	// Reset forgets every key.
+	Reset()
+}
+
+// policy names accepted by PolicyByName
+const (
+	PolicyLRU     = "lru"
+	PolicyLFU     = "lfu"
+	PolicyTinyLFU = "tinylfu"
+)
+
+// PolicyByName builds a policy from its name, "" is LRU. capacity is a
+// sizing hint for policies that keep frequency statistics, 0 picks a default.
+func PolicyByName(name string, capacity int) (Policy, error) {
+	switch name {
+	case "", PolicyLRU:
+		return NewLRUPolicy(), nil
+	case PolicyLFU:
+		return NewLFUPolicy(), nil
+	case PolicyTinyLFU:
+		return NewTinyLFUPolicy(capacity), nil
+	}
+	return nil, fmt.Errorf("unknown eviction policy %q", name)
+}
+
+// lruPolicy evicts the least recently used key. o(1) for everything.
+type lruPolicy struct {
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// NewLRUPolicy returns the default least recently used policy.
+func NewLRUPolicy() Policy {
+	return &lruPolicy{ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (p *lruPolicy) Add(key string) {
+	p.items[key] = p.ll.PushFront(key)
+}
+
+func (p *lruPolicy) Touch(key string) {
+	if elem, ok := p.items[key]; ok {
+		p.ll.MoveToFront(elem)
+	}
+}
+
+func (p *lruPolicy) Remove(key string) {
+	if elem, ok := p.items[key]; ok {
+		p.ll.Remove(elem)
+		delete(p.items, key)
+	}
+}
+
+func (p *lruPolicy) Victim() (string, bool) {
+	elem := p.ll.Back()
+	if elem == nil {
+		return "", false
+	}
+	return elem.Value.(string), true
+}
+
+func (p *lruPolicy) Reset() {
+	p.ll.Init()
+	p.items = make(map[string]*list.Element)
+}
+
+// lfuPolicy evicts the least frequently used key, ties go to the least
+// recently used one. keys sit in per-frequency buckets ordered by frequency,
+// so every operation is o(1). the key added last is spared while there is
+// anything else to evict, otherwise every new key would be its own victim.
+type lfuPolicy struct {
+	buckets *list.List // of *lfuBucket, lowest frequency first
+	items   map[string]*lfuItem
+	newest  string
+}
+
+type lfuBucket struct {
+	freq int
+	keys *list.List // most recently used first
+}
+
+type lfuItem struct {
+	bucket *list.Element
+	elem   *list.Element
+}
+
+// NewLFUPolicy returns a least frequently used policy. it keeps a hot set
+// through one-off scans, but old favourites are slow to age out.
+func NewLFUPolicy() Policy {
+	return &lfuPolicy{buckets: list.New(), items: make(map[string]*lfuItem)}
+}
+
+func (p *lfuPolicy) Add(key string) {
+	front := p.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket).freq != 1 {
+		front = p.buckets.PushFront(&lfuBucket{freq: 1, keys: list.New()})
+	}
+	p.items[key] = &lfuItem{bucket: front, elem: front.Value.(*lfuBucket).keys.PushFront(key)}
+	p.newest = key
+}
+
+func (p *lfuPolicy) Touch(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	cur := item.bucket.Value.(*lfuBucket)
+	next := item.bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != cur.freq+1 {
+		next = p.buckets.InsertAfter(&lfuBucket{freq: cur.freq + 1, keys: list.New()}, item.bucket)
+	}
+	cur.keys.Remove(item.elem)
+	if cur.keys.Len() == 0 {
+		p.buckets.Remove(item.bucket)
+	}
+	item.bucket = next
+	item.elem = next.Value.(*lfuBucket).keys.PushFront(key)
+}
+
+func (p *lfuPolicy) Remove(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	b := item.bucket.Value.(*lfuBucket)
+	b.keys.Remove(item.elem)
+	if b.keys.Len() == 0 {
+		p.buckets.Remove(item.bucket)
+	}
+	delete(p.items, key)
+	if key == p.newest {
+		p.newest = ""
+	}
+}
+
+func (p *lfuPolicy) Victim() (string, bool) {
+	front := p.buckets.Front()
+	if front == nil {
+		return "", false
+	}
+	keys := front.Value.(*lfuBucket).keys
+	if keys.Len() == 1 && keys.Back().Value.(string) == p.newest && front.Next() != nil {
+		keys = front.Next().Value.(*lfuBucket).keys
+	}
+	return keys.Back().Value.(string), true
+}
+
+func (p *lfuPolicy) Reset() {
+	p.buckets.Init()
+	p.items = make(map[string]*lfuItem)
+	p.newest = ""
+}
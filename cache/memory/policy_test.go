@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// hitRatio replays a trace against a cache of the given size, filling in
+// every miss like a read-through caller would.
+func hitRatio(p Policy, size int, trace []string) float64 {
+	c := NewMemorycache()
+	c.SetMaxSize(size)
+	c.SetPolicy(p)
+	hits := 0
+	for _, key := range trace {
+		if _, err := c.Get(key); err == nil {
+			hits++
+			continue
+		}
+		c.Set(key, struct{}{})
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+// zipfTrace draws n keys from a skewed popularity distribution over keys
+// distinct keys.
+func zipfTrace(r *rand.Rand, n, keys int) []string {
+	z := rand.NewZipf(r, 1.1, 1, uint64(keys-1))
+	trace := make([]string, n)
+	for i := range trace {
+		trace[i] = fmt.Sprintf("hot:%d", z.Uint64())
+	}
+	return trace
+}
+
+// scanTrace is a zipf workload where a batch job regularly reads a run of
+// keys that are never seen again.
+func scanTrace(r *rand.Rand, n, keys, every, scanLen int) []string {
+	base := zipfTrace(r, n, keys)
+	trace := make([]string, 0, n+n/every*scanLen)
+	scan := 0
+	for i, key := range base {
+		trace = append(trace, key)
+		if i%every == every-1 {
+			for j := 0; j < scanLen; j++ {
+				trace = append(trace, fmt.Sprintf("scan:%d", scan))
+				scan++
+			}
+		}
+	}
+	return trace
+}
+
+func newPolicies(size int) map[string]Policy {
+	return map[string]Policy{
+		PolicyLRU:     NewLRUPolicy(),
+		PolicyLFU:     NewLFUPolicy(),
+		PolicyTinyLFU: NewTinyLFUPolicy(size),
+	}
+}
+
+func TestPolicyHitRatioZipf(t *testing.T) {
+	const size = 100
+	trace := zipfTrace(rand.New(rand.NewSource(1)), 50000, 2000)
+	for name, p := range newPolicies(size) {
+		ratio := hitRatio(p, size, trace)
+		t.Logf("%s zipf hit ratio: %.3f", name, ratio)
+		// a skewed workload must be served well by every policy
+		if ratio < 0.4 {
+			t.Errorf("%s: hit ratio %.3f is too low for a zipf trace", name, ratio)
+		}
+	}
+}
+
+// one-off scans must not flush the hot set under the frequency based policies
+func TestPolicyHitRatioScan(t *testing.T) {
+	const size = 100
+	trace := scanTrace(rand.New(rand.NewSource(2)), 50000, 2000, 100, 150)
+	ratios := make(map[string]float64)
+	for name, p := range newPolicies(size) {
+		ratios[name] = hitRatio(p, size, trace)
+		t.Logf("%s scan hit ratio: %.3f", name, ratios[name])
+	}
+	for _, name := range []string{PolicyLFU, PolicyTinyLFU} {
+		if ratios[name] < ratios[PolicyLRU]*1.25 {
+			t.Errorf("Expected %s (%.3f) to clearly beat lru (%.3f) on a scan workload",
+				name, ratios[name], ratios[PolicyLRU])
+		}
+	}
+}
+
+func TestLFUEvictsLeastFrequent(t *testing.T) {
+	c := NewMemorycache()
+	c.SetPolicy(NewLFUPolicy())
+	c.SetMaxSize(2)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+	// a:3 b:2, 'b' goes even though it was used last
+	c.Set("c", 3)
+	if _, err := c.Get("b"); err == nil {
+		t.Fatalf("Expected 'b' to be evicted")
+	}
+	if _, err := c.Get("a"); err != nil {
+		t.Fatalf("Expected 'a' to exist, got %v", err)
+	}
+
+	// ties go to the least recently used key
+	c.Delete("c")
+	c.Set("d", 4)
+	c.Set("e", 5)
+	if _, err := c.Get("d"); err == nil {
+		t.Fatalf("Expected 'd' to lose the tie with 'e'")
+	}
+}
+
+// a single frequent key survives a stream of one-off keys
+func TestTinyLFUAdmission(t *testing.T) {
+	c := NewMemorycache()
+	c.SetMaxSize(10)
+	c.SetPolicy(NewTinyLFUPolicy(10))
+	for i := 0; i < 10; i++ {
+		c.Set("hot", i)
+		c.Get("hot")
+	}
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprintf("once:%d", i), i)
+	}
+	if _, err := c.Get("hot"); err != nil {
+		t.Fatalf("Expected 'hot' to survive the scan, got %v", err)
+	}
+	if len(c.data) != 10 {
+		t.Fatalf("Expected 10 entries, got %d", len(c.data))
+	}
+}
+
+// switching policy keeps what is stored
+func TestSetPolicyKeepsEntries(t *testing.T) {
+	c := NewMemorycache()
+	c.SetMaxSize(3)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.SetPolicy(NewLFUPolicy())
+	c.Get("a")
+	c.Set("c", 3)
+	c.Set("d", 4)
+	if len(c.data) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(c.data))
+	}
+	if _, err := c.Get("a"); err != nil {
+		t.Fatalf("Expected 'a' to exist, got %v", err)
+	}
+}
+
+func TestPolicyByName(t *testing.T) {
+	for _, name := range []string{"", PolicyLRU, PolicyLFU, PolicyTinyLFU} {
+		if _, err := PolicyByName(name, 10); err != nil {
+			t.Errorf("PolicyByName(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := PolicyByName("fifo", 10); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
@@ -0,0 +1,307 @@
+package memory
+
+import (
+	"Go-library/cache"
+	"context"
+	"hash/maphash"
+	"time"
+)
+
+// ShardedCache spreads keys over independent Memorycache shards chosen by
+// key hash, so operations on different shards never wait for each other.
+// capacity limits are split evenly between shards, which makes eviction
+// LRU per shard rather than across the whole cache.
+type ShardedCache struct {
+	shards []*Memorycache
+	mask   uint64
+	seed   maphash.Seed
+}
+
+// NewSharded creates a cache with at least n shards, rounded up to a power
+// of two. n < 1 is treated as 1.
+func NewSharded(n int) *ShardedCache {
+	size := 1
+	for size < n {
+		size <<= 1
+	}
+	s := &ShardedCache{
+		shards: make([]*Memorycache, size),
+		mask:   uint64(size - 1),
+		seed:   maphash.MakeSeed(),
+	}
+	for i := range s.shards {
+		s.shards[i] = NewMemorycache()
+	}
+	return s
+}
+
+var _ cache.Cache = (*ShardedCache)(nil)
+var _ cache.ContextCache = (*ShardedCache)(nil)
+var _ cache.BatchCache = (*ShardedCache)(nil)
+var _ cache.StatsProvider = (*ShardedCache)(nil)
+var _ cache.TagCache = (*ShardedCache)(nil)
+var _ cache.CounterCache = (*ShardedCache)(nil)
+var _ cache.ConditionalCache = (*ShardedCache)(nil)
+var _ cache.CapabilityProvider = (*ShardedCache)(nil)
+
+// Capabilities are those of a single Memorycache.
+func (s *ShardedCache) Capabilities() cache.Capabilities {
+	return s.shards[0].Capabilities()
+}
+
+func (s *ShardedCache) shard(key string) *Memorycache {
+	return s.shards[maphash.String(s.seed, key)&s.mask]
+}
+
+// Shards returns the number of shards, n of NewSharded rounded up.
+func (s *ShardedCache) Shards() int {
+	return len(s.shards)
+}
+
+// share of shard i in a total limit. the shares add up to total, but every
+// shard gets at least 1 since 0 means unlimited, so a total below the shard
+// count is exceeded.
+func (s *ShardedCache) share(total int64, i int) int64 {
+	if total <= 0 {
+		return total
+	}
+	n := int64(len(s.shards))
+	sh := total / n
+	if int64(i) < total%n {
+		sh++
+	}
+	return max(sh, 1)
+}
+
+// SetMaxSize limits the total number of items, split between shards. the
+// total is an upper bound unless it is below Shards.
+func (s *ShardedCache) SetMaxSize(size int) {
+	for i, sh := range s.shards {
+		sh.SetMaxSize(int(s.share(int64(size), i)))
+	}
+}
+
+// SetMaxCost limits the total cost of all items, split between shards. the
+// total is an upper bound unless it is below Shards.
+func (s *ShardedCache) SetMaxCost(maxCost int64) {
+	for i, sh := range s.shards {
+		sh.SetMaxCost(s.share(maxCost, i))
+	}
+}
+
+// SetPolicy gives every shard its own policy built by newPolicy.
+func (s *ShardedCache) SetPolicy(newPolicy func() Policy) {
+	for _, sh := range s.shards {
+		sh.SetPolicy(newPolicy())
+	}
+}
+
+// OnEvict registers the eviction callback on every shard.
+func (s *ShardedCache) OnEvict(fn func(key string, value interface{}, reason EvictionReason)) {
+	for _, sh := range s.shards {
+		sh.OnEvict(fn)
+	}
+}
+
+// SetClock hands clock to every shard, see Memorycache.SetClock.
+func (s *ShardedCache) SetClock(clock cache.Clock) {
+	for _, sh := range s.shards {
+		sh.SetClock(clock)
+	}
+}
+
+// StartJanitor starts the expiry janitor of every shard.
+func (s *ShardedCache) StartJanitor(interval time.Duration) {
+	for _, sh := range s.shards {
+		sh.StartJanitor(interval)
+	}
+}
+
+// Close stops the janitors.
+func (s *ShardedCache) Close() error {
+	for _, sh := range s.shards {
+		sh.Close()
+	}
+	return nil
+}
+
+// Len returns the number of items held, expired ones included until purged.
+func (s *ShardedCache) Len() int {
+	n := 0
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		n += len(sh.data)
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// Stats adds up the counters of all shards.
+func (s *ShardedCache) Stats() cache.Stats {
+	var total cache.Stats
+	for _, sh := range s.shards {
+		st := sh.Stats()
+		total.Hits += st.Hits
+		total.Misses += st.Misses
+		total.Sets += st.Sets
+		total.Deletes += st.Deletes
+		total.Evictions += st.Evictions
+		total.Expirations += st.Expirations
+		total.Items += st.Items
+		if st.Bytes < 0 || total.Bytes < 0 {
+			total.Bytes = -1
+		} else {
+			total.Bytes += st.Bytes
+		}
+	}
+	return total
+}
+
+func (s *ShardedCache) Set(key string, value interface{}) error {
+	return s.shard(key).Set(key, value)
+}
+
+func (s *ShardedCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return s.shard(key).SetWithTTL(key, value, ttl)
+}
+
+func (s *ShardedCache) Get(key string) (interface{}, error) {
+	return s.shard(key).Get(key)
+}
+
+func (s *ShardedCache) Delete(key string) error {
+	return s.shard(key).Delete(key)
+}
+
+func (s *ShardedCache) SetWithTags(key string, value interface{}, ttl time.Duration, tags ...string) error {
+	return s.shard(key).SetWithTags(key, value, ttl, tags...)
+}
+
+func (s *ShardedCache) Incr(key string, delta int64) (int64, error) {
+	return s.shard(key).Incr(key, delta)
+}
+
+func (s *ShardedCache) Decr(key string, delta int64) (int64, error) {
+	return s.shard(key).Decr(key, delta)
+}
+
+func (s *ShardedCache) IncrWithTTL(key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.shard(key).IncrWithTTL(key, delta, ttl)
+}
+
+func (s *ShardedCache) DecrWithTTL(key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.shard(key).DecrWithTTL(key, delta, ttl)
+}
+
+func (s *ShardedCache) Add(key string, value interface{}, ttl time.Duration) error {
+	return s.shard(key).Add(key, value, ttl)
+}
+
+func (s *ShardedCache) Replace(key string, value interface{}, ttl time.Duration) error {
+	return s.shard(key).Replace(key, value, ttl)
+}
+
+func (s *ShardedCache) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) (bool, error) {
+	return s.shard(key).CompareAndSwap(key, old, new, ttl)
+}
+
+// InvalidateTag asks every shard, it is not atomic across shards.
+func (s *ShardedCache) InvalidateTag(tag string) error {
+	for _, sh := range s.shards {
+		if err := sh.InvalidateTag(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear empties the shards one after another, it is not atomic across shards.
+func (s *ShardedCache) Clear() error {
+	for _, sh := range s.shards {
+		sh.Clear()
+	}
+	return nil
+}
+
+func (s *ShardedCache) SetCtx(ctx context.Context, key string, value interface{}) error {
+	return s.shard(key).SetCtx(ctx, key, value)
+}
+
+func (s *ShardedCache) SetWithTTLCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return s.shard(key).SetWithTTLCtx(ctx, key, value, ttl)
+}
+
+func (s *ShardedCache) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	return s.shard(key).GetCtx(ctx, key)
+}
+
+func (s *ShardedCache) DeleteCtx(ctx context.Context, key string) error {
+	return s.shard(key).DeleteCtx(ctx, key)
+}
+
+func (s *ShardedCache) ClearCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Clear()
+}
+
+// batches are split per shard, each shard is locked once.
+
+func (s *ShardedCache) GetMulti(keys []string) (map[string]interface{}, error) {
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, len(keys))
+	for sh, group := range s.groupKeys(keys) {
+		vals, err := sh.GetMulti(group)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range vals {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+func (s *ShardedCache) SetMulti(items map[string]interface{}, ttl time.Duration) error {
+	groups := make(map[*Memorycache]map[string]interface{})
+	for key, value := range items {
+		if key == "" {
+			return cache.ErrEmptyKey
+		}
+		sh := s.shard(key)
+		if groups[sh] == nil {
+			groups[sh] = make(map[string]interface{})
+		}
+		groups[sh][key] = value
+	}
+	for sh, group := range groups {
+		if err := sh.SetMulti(group, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ShardedCache) DeleteMulti(keys []string) error {
+	if err := checkKeys(keys); err != nil {
+		return err
+	}
+	for sh, group := range s.groupKeys(keys) {
+		if err := sh.DeleteMulti(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ShardedCache) groupKeys(keys []string) map[*Memorycache][]string {
+	groups := make(map[*Memorycache][]string)
+	for _, key := range keys {
+		sh := s.shard(key)
+		groups[sh] = append(groups[sh], key)
+	}
+	return groups
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/compliance"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestShardedCompliance(t *testing.T) {
+	compliance.RunTest(t, func(t *testing.T) (cache.Cache, func(time.Duration)) {
+		c := NewSharded(8)
+		clock := cache.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		c.SetClock(clock)
+		return c, clock.Advance
+	})
+}
+
+func TestShardedCount(t *testing.T) {
+	for _, tc := range []struct{ in, want int }{{0, 1}, {1, 1}, {3, 4}, {16, 16}, {17, 32}} {
+		if got := len(NewSharded(tc.in).shards); got != tc.want {
+			t.Errorf("NewSharded(%d): expected %d shards, got %d", tc.in, tc.want, got)
+		}
+	}
+}
+
+// the total limit is split between shards and never exceeded
+func TestShardedMaxSize(t *testing.T) {
+	s := NewSharded(3)
+	s.SetMaxSize(102)
+	for i := 0; i < 1000; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+	if n := s.Len(); n > 102 {
+		t.Fatalf("Expected at most 102 items, got %d", n)
+	}
+	var total int64
+	for i := 0; i < s.Shards(); i++ {
+		total += s.share(102, i)
+	}
+	if total != 102 {
+		t.Errorf("Expected the shares to add up to 102, got %d", total)
+	}
+	// recently written keys survive in their shard
+	if _, err := s.Get("999"); err != nil {
+		t.Fatalf("Expected latest key to exist, got %v", err)
+	}
+}
+
+func benchmarkParallelGet(b *testing.B, c cache.Cache) {
+	const keys = 1024
+	for i := 0; i < keys; i++ {
+		c.Set(strconv.Itoa(i), i)
+	}
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(names[i%keys])
+			i++
+		}
+	})
+}
+
+func benchmarkParallelMixed(b *testing.B, c cache.Cache) {
+	const keys = 1024
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+		c.Set(names[i], i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			// one write for every four reads
+			if i%5 == 0 {
+				c.Set(names[i%keys], i)
+			} else {
+				c.Get(names[i%keys])
+			}
+			i++
+		}
+	})
+}
+
+// go test -bench Parallel -cpu 1,8,32 ./cache/memory
+func BenchmarkParallelGet(b *testing.B) {
+	b.Run("Memorycache", func(b *testing.B) { benchmarkParallelGet(b, NewMemorycache()) })
+	b.Run("Sharded16", func(b *testing.B) { benchmarkParallelGet(b, NewSharded(16)) })
+	b.Run("Sharded64", func(b *testing.B) { benchmarkParallelGet(b, NewSharded(64)) })
+}
+
+func BenchmarkParallelMixed(b *testing.B) {
+	b.Run("Memorycache", func(b *testing.B) { benchmarkParallelMixed(b, NewMemorycache()) })
+	b.Run("Sharded16", func(b *testing.B) { benchmarkParallelMixed(b, NewSharded(16)) })
+	b.Run("Sharded64", func(b *testing.B) { benchmarkParallelMixed(b, NewSharded(64)) })
+}
+
+func TestShardedStats(t *testing.T) {
+	s := NewSharded(4)
+	for i := 0; i < 10; i++ {
+		s.Set(strconv.Itoa(i), i)
+		s.Get(strconv.Itoa(i))
+	}
+	s.Get("missing")
+	st := s.Stats()
+	if st.Sets != 10 || st.Hits != 10 || st.Misses != 1 || st.Items != 10 || st.Bytes != -1 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
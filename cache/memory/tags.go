@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"Go-library/cache"
+	"time"
+)
+
+var _ cache.TagCache = (*Memorycache)(nil)
+
+// SetWithTags adds or updates a value and files it under tags, replacing
+// the tags of an earlier write. a ttl of 0 means no expiry, also for a key
+// that had one. any later write without tags drops them.
+func (c *Memorycache) SetWithTags(key string, value interface{}, ttl time.Duration, tags ...string) error {
+	c.mu.Lock()
+	defer c.unlock()
+	if key == "" {
+		return cache.ErrEmptyKey
+	}
+	for _, tag := range tags {
+		if tag == "" {
+			return cache.ErrEmptyTag
+		}
+	}
+	cost := c.costOf(key, value)
+	if c.maxCost > 0 && cost > c.maxCost {
+		return ErrCostTooLarge
+	}
+	e := c.upsert(key, value, cost)
+	if ttl > 0 {
+		c.setExpiry(e, c.clock.Now().Add(ttl))
+	} else {
+		c.clearExpiry(e)
+	}
+	c.tag(e, tags)
+	c.evict()
+	return nil
+}
+
+// InvalidateTag removes every key filed under tag. o(keys in the tag)
+func (c *Memorycache) InvalidateTag(tag string) error {
+	c.mu.Lock()
+	defer c.unlock()
+	if tag == "" {
+		return cache.ErrEmptyTag
+	}
+	for key := range c.tags[tag] {
+		c.removeEntry(c.data[key], EvictionDeleted)
+	}
+	return nil
+}
+
+// tag files e under tags, the caller holds mu.
+func (c *Memorycache) tag(e *entry, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if c.tags == nil {
+		c.tags = make(map[string]map[string]struct{})
+	}
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		if _, dup := keys[e.key]; dup {
+			continue
+		}
+		keys[e.key] = struct{}{}
+		e.tags = append(e.tags, tag)
+	}
+}
+
+// untag takes e out of all its tags, the caller holds mu.
+func (c *Memorycache) untag(e *entry) {
+	for _, tag := range e.tags {
+		delete(c.tags[tag], e.key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+	e.tags = nil
+}
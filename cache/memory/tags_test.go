@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"Go-library/cache"
+	"testing"
+	"time"
+)
+
+// a later write without tags takes the key out of its old tags
+func TestSetDropsTags(t *testing.T) {
+	c := NewMemorycache()
+	c.SetWithTags("k", "v1", 0, "t")
+	c.Set("k", "v2")
+	c.InvalidateTag("t")
+	if val, err := c.Get("k"); err != nil || val != "v2" {
+		t.Errorf("Expected k to survive, got %v, %v", val, err)
+	}
+}
+
+// the tag index does not keep removed keys around
+func TestTagIndexCleanup(t *testing.T) {
+	c := NewMemorycache()
+	c.SetMaxSize(1)
+	c.SetWithTags("a", 1, 0, "t1", "t2")
+	c.SetWithTags("b", 2, time.Minute, "t1")
+	c.Delete("b")
+	if len(c.tags) != 0 {
+		t.Errorf("Expected empty tag index, got %v", c.tags)
+	}
+}
+
+func TestInvalidateTagCallback(t *testing.T) {
+	c := NewMemorycache()
+	var reasons []EvictionReason
+	c.OnEvict(func(key string, value interface{}, reason EvictionReason) {
+		reasons = append(reasons, reason)
+	})
+	c.SetWithTags("a", 1, 0, "t")
+	c.SetWithTags("b", 2, 0, "t")
+	c.InvalidateTag("t")
+	if len(reasons) != 2 || reasons[0] != EvictionDeleted {
+		t.Errorf("Expected two deletions, got %v", reasons)
+	}
+	if _, err := c.Get("a"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected a gone, got %v", err)
+	}
+}
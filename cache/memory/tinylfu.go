@@ -0,0 +1,178 @@
+package memory
+
+import (
+	"container/list"
+	"hash/maphash"
+)
+
+// tinyLFUPolicy is a W-TinyLFU style policy. new keys land in a small LRU
+// window (1% of the entries), keys leaving the window have to beat the main
+// region's LRU victim on estimated access frequency to be admitted. one-off
+// keys from a scan are therefore dropped instead of flushing the hot set.
+//
+// note that admission means a freshly Set key can be the one evicted.
+type tinyLFUPolicy struct {
+	window *list.List
+	main   *list.List
+	items  map[string]*tinyItem
+	sketch *countMinSketch
+}
+
+type tinyItem struct {
+	elem     *list.Element
+	inWindow bool
+}
+
+// NewTinyLFUPolicy returns a W-TinyLFU policy. capacity sizes the frequency
+// sketch and should match the cache's max size, 0 picks a default.
+func NewTinyLFUPolicy(capacity int) Policy {
+	return &tinyLFUPolicy{
+		window: list.New(),
+		main:   list.New(),
+		items:  make(map[string]*tinyItem),
+		sketch: newCountMinSketch(capacity),
+	}
+}
+
+func (p *tinyLFUPolicy) Add(key string) {
+	p.sketch.increment(key)
+	p.items[key] = &tinyItem{elem: p.window.PushFront(key), inWindow: true}
+}
+
+func (p *tinyLFUPolicy) Touch(key string) {
+	p.sketch.increment(key)
+	if item, ok := p.items[key]; ok {
+		p.list(item).MoveToFront(item.elem)
+	}
+}
+
+func (p *tinyLFUPolicy) Remove(key string) {
+	if item, ok := p.items[key]; ok {
+		p.list(item).Remove(item.elem)
+		delete(p.items, key)
+	}
+}
+
+func (p *tinyLFUPolicy) Victim() (string, bool) {
+	total := p.window.Len() + p.main.Len()
+	if total == 0 {
+		return "", false
+	}
+	// the cache asks when it is one entry over, so split what it can hold
+	windowMax := max(1, (total-1)/100)
+	mainMax := total - 1 - windowMax
+	for p.window.Len() > windowMax {
+		// the window overflows: its LRU key asks to join the main region
+		candidate := p.window.Back().Value.(string)
+		item := p.items[candidate]
+		p.window.Remove(item.elem)
+		item.elem, item.inWindow = p.main.PushFront(candidate), false
+		if p.main.Len() <= mainMax {
+			// room left, admitted for free
+			continue
+		}
+
+		victim := p.main.Back().Value.(string)
+		if victim == candidate {
+			return candidate, true
+		}
+		if p.sketch.estimate(candidate) > p.sketch.estimate(victim) {
+			return victim, true
+		}
+		return candidate, true
+	}
+	if back := p.main.Back(); back != nil {
+		return back.Value.(string), true
+	}
+	return p.window.Back().Value.(string), true
+}
+
+func (p *tinyLFUPolicy) Reset() {
+	p.window.Init()
+	p.main.Init()
+	p.items = make(map[string]*tinyItem)
+	p.sketch.clear()
+}
+
+func (p *tinyLFUPolicy) list(item *tinyItem) *list.List {
+	if item.inWindow {
+		return p.window
+	}
+	return p.main
+}
+
+// countMinSketch estimates access frequencies in fixed memory. counters
+// saturate at 15 and are all halved every sampleSize increments so that
+// old popularity fades.
+type countMinSketch struct {
+	rows       [4][]uint8
+	mask       uint64
+	seed       maphash.Seed
+	additions  int
+	sampleSize int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	if capacity <= 0 {
+		capacity = 1024
+	}
+	width := 64
+	for width < capacity {
+		width <<= 1
+	}
+	s := &countMinSketch{
+		mask:       uint64(width - 1),
+		seed:       maphash.MakeSeed(),
+		sampleSize: 10 * width,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index derives the counter of row i from one hash, double hashing style
+func (s *countMinSketch) index(h uint64, i int) uint64 {
+	return (h + uint64(i)*(h>>32|1)) & s.mask
+}
+
+func (s *countMinSketch) increment(key string) {
+	h := maphash.String(s.seed, key)
+	for i := range s.rows {
+		idx := s.index(h, i)
+		if s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.age()
+	}
+}
+
+func (s *countMinSketch) estimate(key string) uint8 {
+	h := maphash.String(s.seed, key)
+	min := uint8(15)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(h, i)]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+func (s *countMinSketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+func (s *countMinSketch) clear() {
+	for i := range s.rows {
+		clear(s.rows[i])
+	}
+	s.additions = 0
+}
@@ -0,0 +1,288 @@
+// Package metrics exposes caches as OpenMetrics series: operation counts by
+// result, latency histograms per operation and, for backends that keep
+// cache.Stats, their item, byte, eviction and expiration numbers.
+package metrics
+
+import (
+	"Go-library/cache"
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ContentType is the media type served by Handler.
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+type operation int
+
+const (
+	opGet operation = iota
+	opSet
+	opSetWithTTL
+	opDelete
+	opClear
+	numOps
+)
+
+var opNames = [numOps]string{"get", "set", "set_with_ttl", "delete", "clear"}
+
+type result int
+
+const (
+	resultOK result = iota
+	resultHit
+	resultMiss
+	resultError
+	numResults
+)
+
+var resultNames = [numResults]string{"ok", "hit", "miss", "error"}
+
+// DefaultBuckets are the latency histogram upper bounds in seconds, from
+// in-process lookups up to slow network round-trips.
+var DefaultBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// Registry collects the caches it wrapped and renders them for scraping.
+type Registry struct {
+	mu      sync.Mutex
+	caches  []*Cache
+	buckets []float64
+}
+
+// NewRegistry returns an empty registry using DefaultBuckets.
+func NewRegistry() *Registry {
+	return &Registry{buckets: DefaultBuckets}
+}
+
+// Cache records every call made through it and passes it on to the wrapped
+// cache. it only implements cache.Cache, wrap the most specific cache last.
+type Cache struct {
+	next    cache.Cache
+	backend string
+	name    string
+	buckets []float64
+
+	calls     [numOps][numResults]atomic.Uint64
+	latencies [numOps]histogram
+}
+
+type histogram struct {
+	counts []atomic.Uint64 // one per bucket, not cumulative
+	count  atomic.Uint64
+	sumNs  atomic.Int64
+}
+
+// Wrap instruments c. backend is the backend type, e.g. string(factory.Redis),
+// and name tells several caches of the same backend apart.
+func (r *Registry) Wrap(c cache.Cache, backend, name string) *Cache {
+	m := &Cache{next: c, backend: backend, name: name, buckets: r.buckets}
+	for i := range m.latencies {
+		m.latencies[i].counts = make([]atomic.Uint64, len(r.buckets))
+	}
+	r.mu.Lock()
+	r.caches = append(r.caches, m)
+	r.mu.Unlock()
+	return m
+}
+
+// Middleware is Wrap as a cache.Middleware, for use with cache.Chain.
+func (r *Registry) Middleware(backend, name string) cache.Middleware {
+	return func(next cache.Cache) cache.Cache {
+		return r.Wrap(next, backend, name)
+	}
+}
+
+var _ cache.Cache = (*Cache)(nil)
+
+// Unwrap returns the instrumented cache.
+func (m *Cache) Unwrap() cache.Cache {
+	return m.next
+}
+
+func (m *Cache) Set(key string, value interface{}) error {
+	start := time.Now()
+	err := m.next.Set(key, value)
+	m.observe(opSet, start, writeResult(err))
+	return err
+}
+
+func (m *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	start := time.Now()
+	err := m.next.SetWithTTL(key, value, ttl)
+	m.observe(opSetWithTTL, start, writeResult(err))
+	return err
+}
+
+func (m *Cache) Get(key string) (interface{}, error) {
+	start := time.Now()
+	val, err := m.next.Get(key)
+	res := resultHit
+	if err == cache.ErrKeyNotFound {
+		res = resultMiss
+	} else if err != nil {
+		res = resultError
+	}
+	m.observe(opGet, start, res)
+	return val, err
+}
+
+func (m *Cache) Delete(key string) error {
+	start := time.Now()
+	err := m.next.Delete(key)
+	res := writeResult(err)
+	if err == cache.ErrKeyNotFound {
+		res = resultMiss
+	}
+	m.observe(opDelete, start, res)
+	return err
+}
+
+func (m *Cache) Clear() error {
+	start := time.Now()
+	err := m.next.Clear()
+	m.observe(opClear, start, writeResult(err))
+	return err
+}
+
+func writeResult(err error) result {
+	if err != nil {
+		return resultError
+	}
+	return resultOK
+}
+
+func (m *Cache) observe(op operation, start time.Time, res result) {
+	elapsed := time.Since(start)
+	m.calls[op][res].Add(1)
+	h := &m.latencies[op]
+	seconds := elapsed.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i].Add(1)
+			break
+		}
+	}
+	h.count.Add(1)
+	h.sumNs.Add(int64(elapsed))
+}
+
+// Handler serves the registry in the OpenMetrics text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+// WriteTo writes every metric family followed by the closing "# EOF".
+func (r *Registry) WriteTo(out io.Writer) (int64, error) {
+	r.mu.Lock()
+	caches := append([]*Cache(nil), r.caches...)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: out}
+	w := bufio.NewWriter(cw)
+
+	family(w, "cache_operations", "counter", "Cache operations by result.")
+	for _, m := range caches {
+		for op := operation(0); op < numOps; op++ {
+			for res := result(0); res < numResults; res++ {
+				n := m.calls[op][res].Load()
+				if n == 0 {
+					continue
+				}
+				fmt.Fprintf(w, "cache_operations_total{%s,operation=%q,result=%q} %d\n",
+					m.labels(), opNames[op], resultNames[res], n)
+			}
+		}
+	}
+
+	family(w, "cache_operation_duration_seconds", "histogram", "Cache operation latency.")
+	for _, m := range caches {
+		for op := operation(0); op < numOps; op++ {
+			h := &m.latencies[op]
+			count := h.count.Load()
+			if count == 0 {
+				continue
+			}
+			labels := fmt.Sprintf("%s,operation=%q", m.labels(), opNames[op])
+			var cumulative uint64
+			for i, bound := range m.buckets {
+				cumulative += h.counts[i].Load()
+				fmt.Fprintf(w, "cache_operation_duration_seconds_bucket{%s,le=%q} %d\n",
+					labels, formatFloat(bound), cumulative)
+			}
+			fmt.Fprintf(w, "cache_operation_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, count)
+			fmt.Fprintf(w, "cache_operation_duration_seconds_sum{%s} %s\n",
+				labels, formatFloat(time.Duration(h.sumNs.Load()).Seconds()))
+			fmt.Fprintf(w, "cache_operation_duration_seconds_count{%s} %d\n", labels, count)
+		}
+	}
+
+	// numbers the backends keep themselves
+	stats := make([]cache.Stats, len(caches))
+	hasStats := make([]bool, len(caches))
+	for i, m := range caches {
+		if sp, ok := m.next.(cache.StatsProvider); ok {
+			stats[i], hasStats[i] = sp.Stats(), true
+		}
+	}
+	backendFamily := func(name, typ, help, suffix string, value func(cache.Stats) int64) {
+		family(w, name, typ, help)
+		for i, m := range caches {
+			if !hasStats[i] {
+				continue
+			}
+			if v := value(stats[i]); v >= 0 {
+				fmt.Fprintf(w, "%s%s{%s} %d\n", name, suffix, m.labels(), v)
+			}
+		}
+	}
+	backendFamily("cache_items", "gauge", "Items held by the backend.", "",
+		func(s cache.Stats) int64 { return s.Items })
+	backendFamily("cache_bytes", "gauge", "Bytes held by the backend.", "",
+		func(s cache.Stats) int64 { return s.Bytes })
+	backendFamily("cache_evictions", "counter", "Items evicted by the backend to make room.", "_total",
+		func(s cache.Stats) int64 { return s.Evictions })
+	backendFamily("cache_expirations", "counter", "Items dropped by the backend after their TTL.", "_total",
+		func(s cache.Stats) int64 { return s.Expirations })
+
+	io.WriteString(w, "# EOF\n")
+	err := w.Flush()
+	return cw.n, err
+}
+
+func family(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n# HELP %s %s\n", name, typ, name, help)
+}
+
+func (m *Cache) labels() string {
+	return fmt.Sprintf("backend=\"%s\",cache=\"%s\"", escape(m.backend), escape(m.name))
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
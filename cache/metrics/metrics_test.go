@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/factory"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	srv := httptest.NewServer(reg.Handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != ContentType {
+		t.Errorf("Expected content type %q, got %q", ContentType, ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading scrape failed: %v", err)
+	}
+	return string(body)
+}
+
+func TestExposition(t *testing.T) {
+	backend, err := factory.New(factory.Memory, factory.Config{MemoryMaxSize: 10})
+	if err != nil {
+		t.Fatalf("Failed to create memory cache: %v", err)
+	}
+	reg := NewRegistry()
+	c := reg.Wrap(backend, string(factory.Memory), "users")
+
+	c.Set("a", 1)
+	c.Get("a")
+	c.Get("a")
+	c.Get("missing")
+	c.Delete("missing")
+	c.Get("")
+
+	body := scrape(t, reg)
+	for _, want := range []string{
+		"# TYPE cache_operations counter",
+		`cache_operations_total{backend="memory",cache="users",operation="set",result="ok"} 1`,
+		`cache_operations_total{backend="memory",cache="users",operation="get",result="hit"} 2`,
+		`cache_operations_total{backend="memory",cache="users",operation="get",result="miss"} 1`,
+		`cache_operations_total{backend="memory",cache="users",operation="get",result="error"} 1`,
+		`cache_operations_total{backend="memory",cache="users",operation="delete",result="miss"} 1`,
+		"# TYPE cache_operation_duration_seconds histogram",
+		`cache_operation_duration_seconds_bucket{backend="memory",cache="users",operation="get",le="+Inf"} 4`,
+		`cache_operation_duration_seconds_count{backend="memory",cache="users",operation="get"} 4`,
+		`cache_items{backend="memory",cache="users"} 1`,
+		`cache_evictions_total{backend="memory",cache="users"} 0`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("missing line %q in:\n%s", want, body)
+		}
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("Expected exposition to end with # EOF")
+	}
+	// bytes are unknown without a cost budget, so no sample
+	if strings.Contains(body, "cache_bytes{") {
+		t.Errorf("Expected no cache_bytes sample for unknown size")
+	}
+}
+
+// bucket counts must be cumulative and end at the total count
+func TestHistogramBuckets(t *testing.T) {
+	reg := NewRegistry()
+	reg.buckets = []float64{0.5, 1}
+	c := reg.Wrap(stubCache{}, "stub", "h")
+	c.observe(opGet, timeAgo(0.2), resultHit)
+	c.observe(opGet, timeAgo(0.7), resultHit)
+	c.observe(opGet, timeAgo(2), resultHit)
+
+	body := scrape(t, reg)
+	for _, want := range []string{
+		`cache_operation_duration_seconds_bucket{backend="stub",cache="h",operation="get",le="0.5"} 1`,
+		`cache_operation_duration_seconds_bucket{backend="stub",cache="h",operation="get",le="1"} 2`,
+		`cache_operation_duration_seconds_bucket{backend="stub",cache="h",operation="get",le="+Inf"} 3`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("missing line %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.Wrap(stubCache{}, "stub", "a\"b\\c\nd")
+	c.Set("k", 1)
+	body := scrape(t, reg)
+	if !strings.Contains(body, `cache="a\"b\\c\nd"`) {
+		t.Errorf("Expected escaped label in:\n%s", body)
+	}
+}
+
+// stubCache accepts everything and keeps nothing, it has no Stats
+type stubCache struct{}
+
+func (stubCache) Set(string, interface{}) error                       { return nil }
+func (stubCache) SetWithTTL(string, interface{}, time.Duration) error { return nil }
+func (stubCache) Get(string) (interface{}, error)                     { return nil, cache.ErrKeyNotFound }
+func (stubCache) Delete(string) error                                 { return nil }
+func (stubCache) Clear() error                                        { return nil }
+
+func timeAgo(seconds float64) time.Time {
+	return time.Now().Add(-time.Duration(seconds * float64(time.Second)))
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"Go-library/cache"
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// refills the bucket for the time since the last call and takes a token.
+// returns 0 when allowed, otherwise the milliseconds until a token is back.
+// a full bucket is the same as a missing one, so the key expires once it
+// would have refilled.
+var tokenBucketScript = goredis.NewScript(`
+local now = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate) + 1)
+return retry
+`)
+
+// TokenBucket holds up to burst tokens per key and adds limit of them every
+// window. each call takes one, so bursts up to burst pass and the long run
+// rate is limit per window.
+type TokenBucket struct {
+	base
+	burst float64
+	rate  float64 // tokens per nanosecond
+}
+
+var _ Limiter = (*TokenBucket)(nil)
+
+// NewTokenBucket refills limit tokens per window into buckets of burst
+// tokens, kept in c. a new bucket starts full. it panics unless limit,
+// window and burst are positive.
+func NewTokenBucket(c cache.Cache, limit int, window time.Duration, burst int) *TokenBucket {
+	checkArgs(limit, window)
+	if burst <= 0 {
+		panic("ratelimit: burst must be positive")
+	}
+	l := &TokenBucket{burst: float64(burst), rate: float64(limit) / float64(window)}
+	l.init(c)
+	return l
+}
+
+// Allow takes a token from key's bucket if it has one.
+func (l *TokenBucket) Allow(key string) (bool, time.Duration) {
+	return allow(l, key)
+}
+
+// AllowCtx is Allow bounded by ctx.
+func (l *TokenBucket) AllowCtx(ctx context.Context, key string) (bool, time.Duration, error) {
+	now := l.now()
+	bkey := l.key(key)
+	if l.script != nil {
+		perMilli := l.rate * float64(time.Millisecond)
+		ms, err := l.script.RunScript(ctx, tokenBucketScript, []string{bkey},
+			now.UnixMilli(), strconv.FormatFloat(perMilli, 'g', -1, 64), l.burst).Int64()
+		if err != nil {
+			return false, 0, err
+		}
+		if ms == 0 {
+			return true, 0, nil
+		}
+		return false, time.Duration(ms) * time.Millisecond, nil
+	}
+
+	var allowed bool
+	var retry time.Duration
+	err := l.update(ctx, bkey, l.refillTime(), func(state string, ok bool) (string, error) {
+		tokens, last := l.burst, now.UnixNano()
+		if ok {
+			t, ts, found := strings.Cut(state, ":")
+			var err1, err2 error
+			tokens, err1 = strconv.ParseFloat(t, 64)
+			last, err2 = strconv.ParseInt(ts, 10, 64)
+			if !found || err1 != nil || err2 != nil {
+				return "", errBadState
+			}
+		}
+		tokens = math.Min(l.burst, tokens+float64(max(now.UnixNano()-last, 0))*l.rate)
+		allowed = tokens >= 1
+		retry = 0
+		if allowed {
+			tokens--
+		} else {
+			retry = time.Duration(math.Ceil((1 - tokens) / l.rate))
+		}
+		return strconv.FormatFloat(tokens, 'g', -1, 64) + ":" + strconv.FormatInt(now.UnixNano(), 10), nil
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed, retry, nil
+}
+
+// refillTime is how long an empty bucket takes to fill, after which its
+// state is no longer needed.
+func (l *TokenBucket) refillTime() time.Duration {
+	return time.Duration(math.Ceil(l.burst/l.rate)) + time.Millisecond
+}
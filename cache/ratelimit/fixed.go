@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"Go-library/cache"
+	"context"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// counts the call in the current window, the key expires with it.
+var fixedWindowScript = goredis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// FixedWindow allows limit calls per key in each window, windows start at
+// multiples of the window length. cheap, but up to twice the limit can pass
+// around a window boundary.
+type FixedWindow struct {
+	base
+	limit  int64
+	window time.Duration
+}
+
+var _ Limiter = (*FixedWindow)(nil)
+
+// NewFixedWindow allows limit calls per window, counted in c. it panics
+// unless limit and window are positive.
+func NewFixedWindow(c cache.Cache, limit int, window time.Duration) *FixedWindow {
+	checkArgs(limit, window)
+	l := &FixedWindow{limit: int64(limit), window: window}
+	l.init(c)
+	return l
+}
+
+// Allow counts the call and allows it while the window is below the limit.
+func (l *FixedWindow) Allow(key string) (bool, time.Duration) {
+	return allow(l, key)
+}
+
+// AllowCtx is Allow bounded by ctx.
+func (l *FixedWindow) AllowCtx(ctx context.Context, key string) (bool, time.Duration, error) {
+	now := l.now()
+	start := now.Truncate(l.window)
+	wkey := l.key(key) + ":" + strconv.FormatInt(start.UnixMilli(), 10)
+	retry := start.Add(l.window).Sub(now)
+
+	var n int64
+	if l.script != nil {
+		var err error
+		n, err = l.script.RunScript(ctx, fixedWindowScript, []string{wkey}, max(l.window.Milliseconds(), 1)).Int64()
+		if err != nil {
+			return false, 0, err
+		}
+	} else {
+		err := l.update(ctx, wkey, l.window, func(state string, ok bool) (string, error) {
+			n = 0
+			if ok {
+				var err error
+				if n, err = strconv.ParseInt(state, 10, 64); err != nil {
+					return "", errBadState
+				}
+			}
+			n++
+			return strconv.FormatInt(n, 10), nil
+		})
+		if err != nil {
+			return false, 0, err
+		}
+	}
+	if n > l.limit {
+		return false, retry, nil
+	}
+	return true, 0, nil
+}
@@ -0,0 +1,203 @@
+// Package ratelimit limits how often a key may do something, with the
+// counts kept in a cache.Cache so every process sharing the backend shares
+// the limit.
+//
+// on a RedisCache each decision is a single Lua script. other backends
+// store the limiter state as a string and update it with CompareAndSwap
+// when they implement cache.ConditionalCache; caches without it are only
+// kept consistent within the process. wrappers, cache.Chain included, are
+// looked through with Unwrap, and cache.WithPrefix with its Prefix method,
+// whose prefix then goes in front of the limiter keys. a wrapper without
+// either hides the backend and gets the in-process fallback.
+package ratelimit
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/redis"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// KeyPrefix goes in front of every key a limiter stores.
+const KeyPrefix = "ratelimit:"
+
+// Limiter decides whether the caller identified by key may go ahead.
+type Limiter interface {
+	// Allow reports whether the call is allowed and, when it is not, how
+	// long until it would be. a backend error allows the call, use
+	// AllowCtx to see it.
+	Allow(key string) (allowed bool, retryAfter time.Duration)
+	// AllowCtx is Allow bounded by ctx that also returns backend errors.
+	AllowCtx(ctx context.Context, key string) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// scripter runs Lua on the server, *redis.RedisCache implements it.
+type scripter interface {
+	RunScript(ctx context.Context, script *goredis.Script, keys []string, args ...interface{}) *goredis.Cmd
+}
+
+var _ scripter = (*redis.RedisCache)(nil)
+
+// errBadState is returned when a key holds something a limiter did not write.
+var errBadState = errors.New("ratelimit: unexpected value in limiter key")
+
+// base is what the limiters share: where the state lives and the clock.
+type base struct {
+	store  cache.Cache
+	script scripter // nil unless the state lives in redis
+	prefix string   // from cache.WithPrefix wrappers around store
+	// serializes updates on caches without ConditionalCache
+	mu sync.Mutex
+
+	clockMu sync.RWMutex
+	clock   cache.Clock
+}
+
+func (b *base) init(c cache.Cache) {
+	b.store, b.prefix = shared(c)
+	b.script, _ = b.store.(scripter)
+	b.clock = cache.SystemClock
+}
+
+// shared looks through wrappers (Unwrap) and tiered caches (L2), a limit
+// must not be served from a process-local tier. the prefixes of the
+// cache.WithPrefix wrappers passed on the way are returned, outermost last.
+func shared(c cache.Cache) (cache.Cache, string) {
+	var prefix string
+	for {
+		switch w := c.(type) {
+		case interface{ Unwrap() cache.Cache }:
+			c = w.Unwrap()
+		case interface{ L2() cache.Cache }:
+			c = w.L2()
+		case interface {
+			Prefix() (string, cache.Cache)
+		}:
+			var p string
+			p, c = w.Prefix()
+			prefix = p + prefix
+		default:
+			return c, prefix
+		}
+	}
+}
+
+// key returns where the state for key is stored.
+func (b *base) key(key string) string {
+	return b.prefix + KeyPrefix + key
+}
+
+// checkArgs panics on limits the limiters cannot work with.
+func checkArgs(limit int, window time.Duration) {
+	if limit <= 0 {
+		panic("ratelimit: limit must be positive")
+	}
+	if window <= 0 {
+		panic("ratelimit: window must be positive")
+	}
+}
+
+// SetClock replaces the clock the limits are measured with, nil restores
+// cache.SystemClock. the redis scripts get the time from it as well, so a
+// cache.FakeClock keeps tests deterministic.
+func (b *base) SetClock(clock cache.Clock) {
+	if clock == nil {
+		clock = cache.SystemClock
+	}
+	b.clockMu.Lock()
+	b.clock = clock
+	b.clockMu.Unlock()
+}
+
+// now reads the clock.
+func (b *base) now() time.Time {
+	b.clockMu.RLock()
+	clock := b.clock
+	b.clockMu.RUnlock()
+	return clock.Now()
+}
+
+// allow turns AllowCtx into Allow, failing open.
+func allow(l Limiter, key string) (bool, time.Duration) {
+	ok, retry, err := l.AllowCtx(context.Background(), key)
+	if err != nil {
+		return true, 0
+	}
+	return ok, retry
+}
+
+// load returns the state string under key, false if there is none.
+func (b *base) load(key string) (string, bool, error) {
+	val, err := b.store.Get(key)
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	switch v := val.(type) {
+	case string:
+		return v, true, nil
+	case []byte:
+		return string(v), true, nil
+	}
+	return "", false, fmt.Errorf("%w: %T", errBadState, val)
+}
+
+// update stores fn's result for the state under key with ttl. fn may run
+// more than once, when another writer changed the state meanwhile.
+func (b *base) update(ctx context.Context, key string, ttl time.Duration, fn func(state string, ok bool) (string, error)) error {
+	cc, ok := b.store.(cache.ConditionalCache)
+	if !ok {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		old, exists, err := b.load(key)
+		if err != nil {
+			return err
+		}
+		next, err := fn(old, exists)
+		if err != nil {
+			return err
+		}
+		return b.store.SetWithTTL(key, next, ttl)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		old, exists, err := b.load(key)
+		if err != nil {
+			return err
+		}
+		next, err := fn(old, exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			err := cc.Add(key, next, ttl)
+			if errors.Is(err, cache.ErrKeyExists) {
+				continue
+			}
+			return err
+		}
+		swapped, err := cc.CompareAndSwap(key, old, next, ttl)
+		if errors.Is(err, cache.ErrKeyNotFound) || (err == nil && !swapped) {
+			continue
+		}
+		return err
+	}
+}
+
+// newID returns a random id.
+func newID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
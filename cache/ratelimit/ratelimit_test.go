@@ -0,0 +1,201 @@
+package ratelimit
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/memory"
+	"Go-library/cache/cache/redis"
+	"Go-library/cache/cache/tiered"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// starts on a minute boundary
+func newFakeClock() *cache.FakeClock {
+	return cache.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+}
+
+// the generic path on memory, the lua path on redis
+func backends(t *testing.T) map[string]cache.Cache {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	rc, err := redis.NewRedisCache(redis.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	return map[string]cache.Cache{"memory": memory.NewMemorycache(), "redis": rc}
+}
+
+func expect(t *testing.T, l Limiter, key string, wantOK bool, wantRetry time.Duration) {
+	t.Helper()
+	ok, retry, err := l.AllowCtx(t.Context(), key)
+	if err != nil {
+		t.Fatalf("AllowCtx failed: %v", err)
+	}
+	// the generic path works in nanoseconds and may round up by one
+	if ok != wantOK || retry < wantRetry || retry > wantRetry+time.Millisecond {
+		t.Errorf("Expected %v after %v, got %v after %v", wantOK, wantRetry, ok, retry)
+	}
+}
+
+func TestFixedWindow(t *testing.T) {
+	for name, c := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := NewFixedWindow(c, 3, time.Minute)
+			l.SetClock(clock)
+
+			clock.Advance(20 * time.Second)
+			for i := 0; i < 3; i++ {
+				expect(t, l, "user:1", true, 0)
+			}
+			expect(t, l, "user:1", false, 40*time.Second)
+			// keys are limited separately
+			expect(t, l, "user:2", true, 0)
+
+			clock.Advance(40 * time.Second)
+			expect(t, l, "user:1", true, 0)
+		})
+	}
+}
+
+func TestSlidingLog(t *testing.T) {
+	for name, c := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := NewSlidingLog(c, 2, 10*time.Second)
+			l.SetClock(clock)
+
+			expect(t, l, "user:1", true, 0)
+			clock.Advance(4 * time.Second)
+			expect(t, l, "user:1", true, 0)
+			clock.Advance(2 * time.Second)
+			expect(t, l, "user:1", false, 4*time.Second)
+			expect(t, l, "user:2", true, 0)
+
+			// the first call leaves the window, the second is still in it
+			clock.Advance(4 * time.Second)
+			expect(t, l, "user:1", true, 0)
+			expect(t, l, "user:1", false, 4*time.Second)
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	for name, c := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := NewTokenBucket(c, 1, time.Second, 2)
+			l.SetClock(clock)
+
+			// a new bucket is full
+			expect(t, l, "user:1", true, 0)
+			expect(t, l, "user:1", true, 0)
+			expect(t, l, "user:1", false, time.Second)
+			expect(t, l, "user:2", true, 0)
+
+			clock.Advance(500 * time.Millisecond)
+			expect(t, l, "user:1", false, 500*time.Millisecond)
+			clock.Advance(500 * time.Millisecond)
+			expect(t, l, "user:1", true, 0)
+			expect(t, l, "user:1", false, time.Second)
+
+			// refilling stops at burst
+			clock.Advance(time.Hour)
+			expect(t, l, "user:1", true, 0)
+			expect(t, l, "user:1", true, 0)
+			expect(t, l, "user:1", false, time.Second)
+		})
+	}
+}
+
+// the limit lives in the shared tier, never in a process-local L1
+func TestTieredUsesL2(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	rc, err := redis.NewRedisCache(redis.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	l := NewFixedWindow(tiered.New(memory.NewMemorycache(), rc, 0), 1, time.Minute)
+	if l.script == nil {
+		t.Fatalf("Expected the limiter to run on the redis tier")
+	}
+}
+
+// hides what it wraps, like a wrapper without Unwrap
+type opaque struct{ cache.Cache }
+
+// without a ConditionalCache updates are serialized in the process
+func TestPlainCache(t *testing.T) {
+	clock := newFakeClock()
+	l := NewFixedWindow(opaque{memory.NewMemorycache()}, 1, time.Minute)
+	l.SetClock(clock)
+	expect(t, l, "user:1", true, 0)
+	expect(t, l, "user:1", false, time.Minute)
+}
+
+// middlewares unwrap to the backend, so redis still runs the script
+func TestChainedBackend(t *testing.T) {
+	c := backends(t)
+	if l := NewFixedWindow(cache.Chain(c["redis"], cache.WithRecovery()), 1, time.Minute); l.script == nil {
+		t.Error("Expected the Lua path behind cache.Chain")
+	}
+	l := NewFixedWindow(cache.Chain(c["memory"], cache.WithRecovery()), 1, time.Minute)
+	if _, ok := l.store.(cache.ConditionalCache); !ok {
+		t.Error("Expected CompareAndSwap behind cache.Chain")
+	}
+}
+
+// a prefix around the backend keeps services sharing a server apart
+func TestPrefixedBackend(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	rc, err := redis.NewRedisCache(redis.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	clock := newFakeClock()
+	a := NewTokenBucket(cache.Chain(rc, cache.WithRecovery(), cache.WithPrefix("svcA:")), 1, time.Minute, 1)
+	b := NewTokenBucket(cache.Chain(rc, cache.WithPrefix("svcB:")), 1, time.Minute, 1)
+	a.SetClock(clock)
+	b.SetClock(clock)
+	if a.script == nil {
+		t.Error("Expected the Lua path behind cache.WithPrefix")
+	}
+	expect(t, a, "user:1", true, 0)
+	expect(t, b, "user:1", true, 0)
+	if !mr.Exists("svcA:" + KeyPrefix + "user:1") {
+		t.Errorf("Expected the limiter key under the prefix, got %v", mr.Keys())
+	}
+}
+
+func TestInvalidArgs(t *testing.T) {
+	c := memory.NewMemorycache()
+	for name, build := range map[string]func(){
+		"fixed limit":   func() { NewFixedWindow(c, 0, time.Minute) },
+		"fixed window":  func() { NewFixedWindow(c, 1, 0) },
+		"sliding limit": func() { NewSlidingLog(c, -1, time.Minute) },
+		"bucket window": func() { NewTokenBucket(c, 1, 0, 1) },
+		"bucket burst":  func() { NewTokenBucket(c, 1, time.Minute, 0) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected a panic")
+				}
+			}()
+			build()
+		})
+	}
+}
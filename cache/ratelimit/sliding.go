@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"Go-library/cache"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// drops the calls older than the window and logs this one if there is
+// room. returns -1 when allowed, otherwise the milliseconds until the
+// oldest call leaves the window.
+var slidingLogScript = goredis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+if redis.call('ZCARD', KEYS[1]) < tonumber(ARGV[3]) then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], window)
+	return -1
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return tonumber(oldest[2]) + window - now
+`)
+
+// SlidingLog allows limit calls per key in any span of window, by logging
+// the time of every allowed call. exact, but stores up to limit entries per
+// key.
+type SlidingLog struct {
+	base
+	limit  int
+	window time.Duration
+}
+
+var _ Limiter = (*SlidingLog)(nil)
+
+// NewSlidingLog allows limit calls per window, logged in c. it panics
+// unless limit and window are positive.
+func NewSlidingLog(c cache.Cache, limit int, window time.Duration) *SlidingLog {
+	checkArgs(limit, window)
+	l := &SlidingLog{limit: limit, window: window}
+	l.init(c)
+	return l
+}
+
+// Allow logs the call if fewer than limit calls were allowed in the last
+// window.
+func (l *SlidingLog) Allow(key string) (bool, time.Duration) {
+	return allow(l, key)
+}
+
+// AllowCtx is Allow bounded by ctx.
+func (l *SlidingLog) AllowCtx(ctx context.Context, key string) (bool, time.Duration, error) {
+	now := l.now()
+	lkey := l.key(key)
+	if l.script != nil {
+		// calls in the same millisecond need distinct members
+		member := strconv.FormatInt(now.UnixMilli(), 10) + "-" + newID()
+		ms, err := l.script.RunScript(ctx, slidingLogScript, []string{lkey},
+			now.UnixMilli(), max(l.window.Milliseconds(), 1), l.limit, member).Int64()
+		if err != nil {
+			return false, 0, err
+		}
+		if ms < 0 {
+			return true, 0, nil
+		}
+		return false, time.Duration(ms) * time.Millisecond, nil
+	}
+
+	var allowed bool
+	var retry time.Duration
+	err := l.update(ctx, lkey, l.window, func(state string, ok bool) (string, error) {
+		cutoff := now.Add(-l.window).UnixNano()
+		var times []int64
+		if ok && state != "" {
+			for _, f := range strings.Split(state, ",") {
+				t, err := strconv.ParseInt(f, 10, 64)
+				if err != nil {
+					return "", errBadState
+				}
+				if t > cutoff {
+					times = append(times, t)
+				}
+			}
+		}
+		allowed = len(times) < l.limit
+		retry = 0
+		if allowed {
+			times = append(times, now.UnixNano())
+		} else if len(times) > 0 {
+			retry = time.Duration(times[0] - cutoff)
+		}
+		fields := make([]string, len(times))
+		for i, t := range times {
+			fields[i] = strconv.FormatInt(t, 10)
+		}
+		return strings.Join(fields, ","), nil
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed, retry, nil
+}
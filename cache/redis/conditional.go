@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"Go-library/cache"
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var _ cache.ConditionalCache = (*RedisCache)(nil)
+
+// swaps the value if it still holds the expected bytes. returns 1 when
+// swapped, 0 on a different value and -1 for a missing key.
+var casScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if not cur then
+	return -1
+end
+if cur ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// Add stores value with SET NX.
+func (c *RedisCache) Add(key string, value interface{}, ttl time.Duration) error {
+	return c.AddCtx(context.Background(), key, value, ttl)
+}
+
+// AddCtx is Add bounded by ctx.
+func (c *RedisCache) AddCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if key == "" {
+		return cache.ErrEmptyKey
+	}
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	ok, err := c.client.SetNX(ctx, c.key(key), data, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return cache.ErrKeyExists
+	}
+	c.stats.AddSets(1)
+	return nil
+}
+
+// Replace stores value with SET XX.
+func (c *RedisCache) Replace(key string, value interface{}, ttl time.Duration) error {
+	return c.ReplaceCtx(context.Background(), key, value, ttl)
+}
+
+// ReplaceCtx is Replace bounded by ctx.
+func (c *RedisCache) ReplaceCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if key == "" {
+		return cache.ErrEmptyKey
+	}
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	ok, err := c.client.SetXX(ctx, c.key(key), data, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return cache.ErrKeyNotFound
+	}
+	c.stats.AddSets(1)
+	return nil
+}
+
+// CompareAndSwap swaps in a Lua script. old is encoded with the codec and
+// compared byte for byte, so it must encode the way the stored value did.
+func (c *RedisCache) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) (bool, error) {
+	return c.CompareAndSwapCtx(context.Background(), key, old, new, ttl)
+}
+
+// CompareAndSwapCtx is CompareAndSwap bounded by ctx.
+func (c *RedisCache) CompareAndSwapCtx(ctx context.Context, key string, old, new interface{}, ttl time.Duration) (bool, error) {
+	if key == "" {
+		return false, cache.ErrEmptyKey
+	}
+	oldData, err := c.codec.Marshal(old)
+	if err != nil {
+		return false, err
+	}
+	newData, err := c.codec.Marshal(new)
+	if err != nil {
+		return false, err
+	}
+	var px int64
+	if ttl > 0 {
+		px = max(ttl.Milliseconds(), 1)
+	}
+	res, err := casScript.Run(ctx, c.client, []string{c.key(key)}, oldData, newData, px).Int64()
+	if err != nil {
+		return false, err
+	}
+	switch res {
+	case -1:
+		return false, cache.ErrKeyNotFound
+	case 0:
+		return false, nil
+	}
+	c.stats.AddSets(1)
+	return true, nil
+}
@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"Go-library/cache"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var _ cache.CounterCache = (*RedisCache)(nil)
+
+// INCRBY that gives the key a ttl only when it creates it.
+var incrScript = redis.NewScript(`
+local created = redis.call('EXISTS', KEYS[1]) == 0
+local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if created and tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return n
+`)
+
+// Incr adds delta with INCRBY. counters are stored as decimal text, which
+// the JSON and raw codecs can read back with Get.
+func (c *RedisCache) Incr(key string, delta int64) (int64, error) {
+	return c.IncrWithTTLCtx(context.Background(), key, delta, 0)
+}
+
+// Decr subtracts delta with INCRBY, see Incr.
+func (c *RedisCache) Decr(key string, delta int64) (int64, error) {
+	return c.IncrWithTTLCtx(context.Background(), key, -delta, 0)
+}
+
+// IncrWithTTL is Incr that expires a key it creates after ttl.
+func (c *RedisCache) IncrWithTTL(key string, delta int64, ttl time.Duration) (int64, error) {
+	return c.IncrWithTTLCtx(context.Background(), key, delta, ttl)
+}
+
+// DecrWithTTL is Decr that expires a key it creates after ttl.
+func (c *RedisCache) DecrWithTTL(key string, delta int64, ttl time.Duration) (int64, error) {
+	return c.IncrWithTTLCtx(context.Background(), key, -delta, ttl)
+}
+
+// IncrWithTTLCtx is IncrWithTTL bounded by ctx. without a ttl it is a plain
+// INCRBY, with one a script that checks for the key in the same step.
+func (c *RedisCache) IncrWithTTLCtx(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	if key == "" {
+		return 0, cache.ErrEmptyKey
+	}
+	var n int64
+	var err error
+	if ttl > 0 {
+		n, err = incrScript.Run(ctx, c.client, []string{c.key(key)}, delta, max(ttl.Milliseconds(), 1)).Int64()
+	} else {
+		n, err = c.client.IncrBy(ctx, c.key(key), delta).Result()
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "not an integer") {
+			return 0, cache.ErrNotInteger
+		}
+		return 0, err
+	}
+	c.stats.AddSets(1)
+	return n, nil
+}
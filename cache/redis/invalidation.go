@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidationBus spreads key invalidations between processes over redis
+// pub/sub, so that every process can drop its local copies of the keys.
+// a bus does not hear its own messages, the process that made the change
+// has already dealt with its local copy.
+type InvalidationBus struct {
+	client  *redis.Client
+	channel string
+	origin  string
+	pubsub  *redis.PubSub
+
+	mu       sync.RWMutex
+	handlers []func(keys []string)
+
+	done chan struct{}
+}
+
+// wire format of an invalidation, no keys means everything
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys,omitempty"`
+}
+
+// NewInvalidationBus subscribes to channel on the server rc talks to. the
+// subscription is live when it returns. call Close to stop it.
+func NewInvalidationBus(rc *RedisCache, channel string) (*InvalidationBus, error) {
+	var id [8]byte
+	rand.Read(id[:])
+	ctx := context.Background()
+	ps := rc.client.Subscribe(ctx, channel)
+	// the first reply confirms the subscription
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	b := &InvalidationBus{
+		client:  rc.client,
+		channel: channel,
+		origin:  hex.EncodeToString(id[:]),
+		pubsub:  ps,
+		done:    make(chan struct{}),
+	}
+	go b.listen()
+	return b, nil
+}
+
+// Publish tells the other subscribers to drop keys, or everything when no
+// keys are given.
+func (b *InvalidationBus) Publish(ctx context.Context, keys ...string) error {
+	msg, err := json.Marshal(invalidation{Origin: b.origin, Keys: keys})
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, msg).Err()
+}
+
+// Subscribe registers fn for invalidations from other processes. keys is
+// nil when everything has to go. fn runs on the bus goroutine, one message
+// at a time.
+func (b *InvalidationBus) Subscribe(fn func(keys []string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, fn)
+}
+
+// Close ends the subscription and waits for the running handler to return.
+func (b *InvalidationBus) Close() error {
+	err := b.pubsub.Close()
+	<-b.done
+	return err
+}
+
+func (b *InvalidationBus) listen() {
+	defer close(b.done)
+	for m := range b.pubsub.Channel() {
+		var inv invalidation
+		// not ours to read, someone else is using the channel
+		if json.Unmarshal([]byte(m.Payload), &inv) != nil || inv.Origin == "" {
+			continue
+		}
+		if inv.Origin == b.origin {
+			continue
+		}
+		b.mu.RLock()
+		handlers := b.handlers
+		b.mu.RUnlock()
+		for _, fn := range handlers {
+			fn(inv.Keys)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package redis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestBus(t *testing.T, rc *RedisCache) (*InvalidationBus, chan []string) {
+	b, err := NewInvalidationBus(rc, "invalidations")
+	if err != nil {
+		t.Fatalf("failed to create bus: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+	got := make(chan []string, 10)
+	b.Subscribe(func(keys []string) { got <- keys })
+	return b, got
+}
+
+func TestInvalidationBus(t *testing.T) {
+	c1, mr := newTestCacheStructure(t)
+	c2, err := NewRedisCache(RedisConfig{Addr: mr.Addr(), DB: 10})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	b1, got1 := newTestBus(t, c1)
+	_, got2 := newTestBus(t, c2)
+
+	ctx := context.Background()
+	if err := b1.Publish(ctx, "a", "b"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	select {
+	case keys := <-got2:
+		if !reflect.DeepEqual(keys, []string{"a", "b"}) {
+			t.Errorf("Expected [a b], got %v", keys)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("invalidation never arrived")
+	}
+
+	// no keys means everything
+	b1.Publish(ctx)
+	select {
+	case keys := <-got2:
+		if keys != nil {
+			t.Errorf("Expected nil keys, got %v", keys)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("clear invalidation never arrived")
+	}
+
+	// foreign messages on the channel are ignored
+	mr.Publish("invalidations", "not json")
+	b1.Publish(ctx, "c")
+	select {
+	case keys := <-got2:
+		if !reflect.DeepEqual(keys, []string{"c"}) {
+			t.Errorf("Expected [c], got %v", keys)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("invalidation never arrived")
+	}
+
+	// the publisher does not hear itself
+	select {
+	case keys := <-got1:
+		t.Errorf("publisher got its own invalidation %v", keys)
+	default:
+	}
+}
@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"Go-library/cache"
+	"context"
+	"iter"
+	"strings"
+)
+
+var _ cache.IterableCache = (*RedisCache)(nil)
+
+// keys asked for per SCAN round trip
+const scanCount = 500
+
+// Scan walks the keys starting with prefix with a SCAN cursor and calls fn
+// with batches of them until fn returns false. SCAN may report a key more
+// than once, and keys added or removed during the walk may be missed. tag
+// bookkeeping keys and locks are skipped.
+func (c *RedisCache) Scan(ctx context.Context, prefix string, fn func(keys []string) bool) error {
+	match := globEscape(c.prefix+prefix) + "*"
+	var cursor uint64
+	for {
+		rkeys, next, err := c.client.Scan(ctx, cursor, match, scanCount).Result()
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(rkeys))
+		for _, rkey := range rkeys {
+			key := rkey[len(c.prefix):]
+			if strings.HasPrefix(key, tagPrefix) || strings.HasPrefix(rkey, lockPrefix) {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		if len(keys) > 0 && !fn(keys) {
+			return nil
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Keys yields the keys starting with prefix, see Scan. the walk stops
+// quietly on a server error, use Scan to see it.
+func (c *RedisCache) Keys(prefix string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		c.Scan(context.Background(), prefix, func(keys []string) bool {
+			for _, key := range keys {
+				if !yield(key) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
+
+// Range yields the keys starting with prefix with their values, fetched
+// with one MGET per SCAN batch. keys that vanish before the MGET are left
+// out, and listing does not count in Stats. the walk stops quietly on a
+// server error or a value that does not decode.
+func (c *RedisCache) Range(prefix string) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		ctx := context.Background()
+		c.Scan(ctx, prefix, func(keys []string) bool {
+			vals, err := c.mget(ctx, keys)
+			if err != nil {
+				return false
+			}
+			for _, key := range keys {
+				val, ok := vals[key]
+				if !ok {
+					continue
+				}
+				if !yield(key, val) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// lockPrefix marks the keys of lock.Acquire, keys starting with it are
+// reserved. a lock lives in "__lock:<namespace>:<name>" and its fencing
+// counter in "__lock:<namespace>:<name>:fence", which has no TTL so tokens
+// keep growing. both stay outside the namespace and Clear skips them, so a
+// Clear neither releases locks nor restarts the tokens.
+const lockPrefix = "__lock:"
+
+// lockKey maps a lock name to its redis key.
+func (c *RedisCache) lockKey(name string) string {
+	return lockPrefix + c.prefix + name
+}
+
+// takes the lock with SET NX PX and hands out the next fencing token in the
+// same step, 0 when the lock is held.
+var lockScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+// extends the lock if owner still holds it.
+var refreshLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// deletes the lock if owner still holds it.
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// TryLock takes the lock name for owner unless it is held, and returns a
+// fencing token larger than any given out before for name.
+func (c *RedisCache) TryLock(ctx context.Context, name, owner string, ttl time.Duration) (int64, bool, error) {
+	key := c.lockKey(name)
+	token, err := lockScript.Run(ctx, c.client, []string{key, key + ":fence"}, owner, max(ttl.Milliseconds(), 1)).Int64()
+	if err != nil {
+		return 0, false, err
+	}
+	return token, token > 0, nil
+}
+
+// RefreshLock gives the lock a new ttl if owner still holds it.
+func (c *RedisCache) RefreshLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	n, err := refreshLockScript.Run(ctx, c.client, []string{c.lockKey(name)}, owner, max(ttl.Milliseconds(), 1)).Int64()
+	return n == 1, err
+}
+
+// Unlock releases the lock if owner still holds it.
+func (c *RedisCache) Unlock(ctx context.Context, name, owner string) (bool, error) {
+	n, err := unlockScript.Run(ctx, c.client, []string{c.lockKey(name)}, owner).Int64()
+	return n == 1, err
+}
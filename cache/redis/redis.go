@@ -2,8 +2,12 @@ package redis
 
 import (
 	"Go-library/cache"
+	"Go-library/cache/cache/codec"
+	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -12,16 +16,34 @@ import (
 // redis sturcture for cache.cache
 type RedisCache struct {
 	client *redis.Client
+	codec  codec.Codec
+	stats  cache.StatsRecorder
+	// namespace + ":", empty without a namespace
+	prefix string
 }
 
 type RedisConfig struct {
 	Addr     string
 	Password string
 	DB       int
+	// Codec serializes values, nil means codec.JSON
+	Codec codec.Codec
+	// Namespace prefixes every key with "<Namespace>:" and limits Clear to
+	// those keys, so servers can be shared. empty means no prefix and a
+	// Clear that empties the whole db but for the locks. it must not
+	// contain ':', or "team" would also cover "team:billing".
+	Namespace string
 }
 
+// ErrInvalidNamespace is returned for a namespace containing the ':'
+// separator.
+var ErrInvalidNamespace = errors.New("redis: namespace must not contain ':'")
+
 // constructor for redisCache
 func NewRedisCache(cfc RedisConfig) (*RedisCache, error) {
+	if strings.Contains(cfc.Namespace, ":") {
+		return nil, ErrInvalidNamespace
+	}
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     cfc.Addr,
 		Password: cfc.Password,
@@ -29,87 +51,352 @@ func NewRedisCache(cfc RedisConfig) (*RedisCache, error) {
 	})
 	ctx := context.Background()
 	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
 		return nil, err
 	}
 
-	return &RedisCache{
+	cd := cfc.Codec
+	if cd == nil {
+		cd = codec.JSON
+	}
+	c := &RedisCache{
 		client: rdb,
-	}, nil
+		codec:  cd,
+	}
+	if cfc.Namespace != "" {
+		c.prefix = cfc.Namespace + ":"
+	}
+	return c, nil
+}
+
+// key maps a cache key to the redis key.
+func (c *RedisCache) key(key string) string {
+	return c.prefix + key
+}
+
+// RunScript runs a Lua script with keys mapped into the namespace, for
+// callers that need several commands applied atomically.
+func (c *RedisCache) RunScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) *redis.Cmd {
+	rkeys := make([]string, len(keys))
+	for i, key := range keys {
+		rkeys[i] = c.key(key)
+	}
+	return script.Run(ctx, c.client, rkeys, args...)
 }
 
 // chekf id redis cache can create interface with Cache
 var _ cache.Cache = (*RedisCache)(nil)
 
+var _ cache.ContextCache = (*RedisCache)(nil)
+
+// Codec returns the codec values are stored with.
+func (c *RedisCache) Codec() codec.Codec {
+	return c.codec
+}
+
+var _ cache.CapabilityProvider = (*RedisCache)(nil)
+
+// Capabilities: every optional interface. Clear is scoped with a namespace,
+// and the raw codec limits the values to []byte and string.
+func (c *RedisCache) Capabilities() cache.Capabilities {
+	return cache.Capabilities{
+		AnyValue:    c.codec != codec.Raw,
+		Context:     true,
+		Batch:       true,
+		Stats:       true,
+		Tags:        true,
+		Counters:    true,
+		Conditional: true,
+		Iteration:   true,
+		Distributed: true,
+		ScopedClear: c.prefix != "",
+	}
+}
+
+// Close closes the connection pool, an InvalidationBus on this cache stops
+// working too.
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
+
 // redis client setup
 
 // adds or updates a value in the cache.
 func (c *RedisCache) Set(key string, value interface{}) error {
-	if key == "" {
-		return cache.ErrEmptyKey
-	}
-	data, err := json.Marshal(value)
-	if err != nil {
-		return err
-	}
-	ctx := context.Background()
-
-	// implementation
-	c.client.Set(ctx, key, data, 0).Err()
-	return nil
+	return c.SetCtx(context.Background(), key, value)
 }
 
 // adds or updates a value in the cache with a TTL.
 func (c *RedisCache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.SetWithTTLCtx(context.Background(), key, value, ttl)
+}
 
+// retrieves a value from the cache.
+func (c *RedisCache) Get(key string) (interface{}, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+// removes a key from the cache.
+func (c *RedisCache) Delete(key string) error {
+	return c.DeleteCtx(context.Background(), key)
+}
+
+// removes all keys from the cache.
+func (c *RedisCache) Clear() error {
+	return c.ClearCtx(context.Background())
+}
+
+// SetCtx adds or updates a value, bounded by ctx.
+func (c *RedisCache) SetCtx(ctx context.Context, key string, value interface{}) error {
+	return c.SetWithTTLCtx(ctx, key, value, 0)
+}
+
+// SetWithTTLCtx adds or updates a value with a TTL, bounded by ctx.
+// a ttl of 0 means the key never expires.
+func (c *RedisCache) SetWithTTLCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	if key == "" {
 		return cache.ErrEmptyKey
 	}
-	data, err := json.Marshal(value)
+	data, err := c.codec.Marshal(value)
 	if err != nil {
 		return err
 	}
-	ctx := context.Background()
-	return c.client.Set(ctx, key, data, ttl).Err()
+	if err := c.client.Set(ctx, c.key(key), data, ttl).Err(); err != nil {
+		return err
+	}
+	c.stats.AddSets(1)
+	return nil
 }
 
-// retrieves a value from the cache.
-func (c *RedisCache) Get(key string) (interface{}, error) {
+// GetCtx retrieves a value, bounded by ctx.
+func (c *RedisCache) GetCtx(ctx context.Context, key string) (interface{}, error) {
 	if key == "" {
 		return nil, cache.ErrEmptyKey
 	}
-	ctx := context.Background()
-	data, err := c.client.Get(ctx, key).Bytes()
+	data, err := c.client.Get(ctx, c.key(key)).Bytes()
 	if err == redis.Nil {
+		c.stats.AddMisses(1)
 		return nil, cache.ErrKeyNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
+	c.stats.AddHits(1)
 	var out interface{}
-	if err := json.Unmarshal(data, &out); err != nil {
+	if err := c.codec.Unmarshal(data, &out); err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// removes a key from the cache.
-func (c *RedisCache) Delete(key string) error {
+// DeleteCtx removes a key, bounded by ctx.
+func (c *RedisCache) DeleteCtx(ctx context.Context, key string) error {
 	if key == "" {
 		return cache.ErrEmptyKey
 	}
-	ctx := context.Background()
-	isDeleted, err := c.client.Del(ctx, key).Result()
+	isDeleted, err := c.client.Del(ctx, c.key(key)).Result()
 	if err != nil {
 		return err
 	}
 	if isDeleted == 0 {
 		return cache.ErrKeyNotFound
 	}
+	c.stats.AddDeletes(isDeleted)
 	return nil
 }
 
-// removes all keys from the cache.
-func (c *RedisCache) Clear() error {
+// ClearCtx removes all keys, bounded by ctx, by SCAN and UNLINK in batches.
+// with a namespace only its keys are removed. locks are kept, see
+// lockPrefix, so even without a namespace this walks the whole keyspace,
+// o(keys in the db), where FLUSHDB would be one command. keys written
+// while that runs may survive.
+func (c *RedisCache) ClearCtx(ctx context.Context) error {
+	iter := c.client.Scan(ctx, 0, globEscape(c.prefix)+"*", clearBatch).Iterator()
+	batch := make([]string, 0, clearBatch)
+	for iter.Next(ctx) {
+		if strings.HasPrefix(iter.Val(), lockPrefix) {
+			continue
+		}
+		batch = append(batch, iter.Val())
+		if len(batch) == clearBatch {
+			if err := c.client.Unlink(ctx, batch...).Err(); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return c.client.Unlink(ctx, batch...).Err()
+	}
+	return nil
+}
+
+// keys scanned and unlinked per round trip by a namespaced Clear
+const clearBatch = 500
+
+// globEscape quotes the characters SCAN MATCH treats as pattern syntax.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var _ cache.BatchCache = (*RedisCache)(nil)
+
+// GetMulti fetches all keys with a single MGET.
+func (c *RedisCache) GetMulti(keys []string) (map[string]interface{}, error) {
+	if err := checkKeys(keys); err != nil {
+		return nil, err
+	}
+	out, err := c.mget(context.Background(), keys)
+	if err != nil {
+		return nil, err
+	}
+	c.stats.AddHits(int64(len(out)))
+	c.stats.AddMisses(int64(len(keys) - len(out)))
+	return out, nil
+}
+
+// mget is GetMulti without the stats.
+func (c *RedisCache) mget(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(keys))
+	if len(keys) == 0 {
+		return out, nil
+	}
+	rkeys := make([]string, len(keys))
+	for i, key := range keys {
+		rkeys[i] = c.key(key)
+	}
+	vals, err := c.client.MGet(ctx, rkeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range vals {
+		// missing keys come back as nil
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var val interface{}
+		if err := c.codec.Unmarshal([]byte(s), &val); err != nil {
+			return nil, err
+		}
+		out[keys[i]] = val
+	}
+	return out, nil
+}
+
+// SetMulti writes all items in one pipeline, ttl 0 means no expiry.
+func (c *RedisCache) SetMulti(items map[string]interface{}, ttl time.Duration) error {
+	encoded := make(map[string][]byte, len(items))
+	for key, value := range items {
+		if key == "" {
+			return cache.ErrEmptyKey
+		}
+		data, err := c.codec.Marshal(value)
+		if err != nil {
+			return err
+		}
+		encoded[key] = data
+	}
+	if len(encoded) == 0 {
+		return nil
+	}
+	_, err := c.client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for key, data := range encoded {
+			pipe.Set(context.Background(), c.key(key), data, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.stats.AddSets(int64(len(encoded)))
+	return nil
+}
+
+// DeleteMulti removes all keys with a single DEL.
+func (c *RedisCache) DeleteMulti(keys []string) error {
+	if err := checkKeys(keys); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	rkeys := make([]string, len(keys))
+	for i, key := range keys {
+		rkeys[i] = c.key(key)
+	}
+	n, err := c.client.Del(context.Background(), rkeys...).Result()
+	if err != nil {
+		return err
+	}
+	c.stats.AddDeletes(n)
+	return nil
+}
+
+func checkKeys(keys []string) error {
+	for _, key := range keys {
+		if key == "" {
+			return cache.ErrEmptyKey
+		}
+	}
+	return nil
+}
+
+var _ cache.StatsProvider = (*RedisCache)(nil)
+
+// Stats returns the operations counted by this client. Items is the DBSIZE
+// of the selected database, unknown (-1) with a namespace. Evictions,
+// Expirations and Bytes (used_memory) come from the server's INFO and cover
+// the whole server. values the server does not report stay as counted
+// locally (0) or unknown (-1).
+func (c *RedisCache) Stats() cache.Stats {
+	s := c.stats.Snapshot()
 	ctx := context.Background()
-	return c.client.FlushDB(ctx).Err()
+	if c.prefix == "" {
+		if n, err := c.client.DBSize(ctx).Result(); err == nil {
+			s.Items = n
+		}
+	}
+	info, err := c.client.Info(ctx).Result()
+	if err != nil {
+		return s
+	}
+	fields := parseInfo(info)
+	if v, ok := fields["evicted_keys"]; ok {
+		s.Evictions = v
+	}
+	if v, ok := fields["expired_keys"]; ok {
+		s.Expirations = v
+	}
+	if v, ok := fields["used_memory"]; ok {
+		s.Bytes = v
+	}
+	return s
+}
+
+// parseInfo reads the numeric "name:value" lines of an INFO reply.
+func parseInfo(info string) map[string]int64 {
+	fields := make(map[string]int64)
+	sc := bufio.NewScanner(strings.NewReader(info))
+	for sc.Scan() {
+		name, value, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if !ok || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			fields[name] = n
+		}
+	}
+	return fields
 }
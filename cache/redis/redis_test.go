@@ -2,7 +2,13 @@ package redis
 
 import (
 	"Go-library/cache"
+	"Go-library/cache/cache/codec"
 	"Go-library/cache/cache/compliance"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"slices"
 	"testing"
 	"time"
 
@@ -55,3 +61,260 @@ func TestSetWithTTLSpecific(t *testing.T) {
 		t.Fatalf("Expected key not found, %v", get)
 	}
 }
+
+type codecSample struct {
+	Name  string
+	Count int
+}
+
+func init() {
+	gob.Register(codecSample{})
+}
+
+// the compliance suite must hold for every codec that takes arbitrary values
+func TestComplianceCodecs(t *testing.T) {
+	for _, cd := range []codec.Codec{codec.Gob, codec.Msgpack} {
+		t.Run(cd.Name(), func(t *testing.T) {
+			compliance.RunTest(t, func(t *testing.T) (cache.Cache, func(time.Duration)) {
+				mr, err := miniredis.Run()
+				if err != nil {
+					t.Fatalf("failed to start miniredis: %v", err)
+				}
+				t.Cleanup(mr.Close)
+				c, err := NewRedisCache(RedisConfig{Addr: mr.Addr(), Codec: cd})
+				if err != nil {
+					t.Fatalf("failed to create redis cache: %v", err)
+				}
+				return c, mr.FastForward
+			})
+		})
+	}
+}
+
+// gob keeps the concrete type, json flattens structs into maps
+func TestCodecValueTypes(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	in := codecSample{Name: "a", Count: 2}
+	gobCache, err := NewRedisCache(RedisConfig{Addr: mr.Addr(), Codec: codec.Gob})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	gobCache.Set("k", in)
+	got, err := gobCache.Get("k")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got != in {
+		t.Errorf("Expected %+v, got %#v", in, got)
+	}
+
+	jsonCache, err := NewRedisCache(RedisConfig{Addr: mr.Addr(), DB: 1})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	jsonCache.Set("k", in)
+	got, err = jsonCache.Get("k")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, ok := got.(map[string]interface{}); !ok {
+		t.Errorf("Expected map[string]interface{} from json codec, got %T", got)
+	}
+
+	rawCache, err := NewRedisCache(RedisConfig{Addr: mr.Addr(), DB: 2, Codec: codec.Raw})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	if err := rawCache.Set("k", in); !errors.Is(err, codec.ErrUnsupportedValue) {
+		t.Errorf("Expected ErrUnsupportedValue from raw codec, got %v", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	c, _ := newTestCacheStructure(t)
+	c.Set("a", 1)
+	c.Get("a")
+	c.Get("missing")
+	c.SetMulti(map[string]interface{}{"b": 2, "c": 3}, 0)
+	c.GetMulti([]string{"b", "c", "nope"})
+	c.Delete("a")
+	c.DeleteMulti([]string{"b", "nope"})
+
+	s := c.Stats()
+	if s.Hits != 3 || s.Misses != 2 || s.Sets != 3 || s.Deletes != 2 {
+		t.Fatalf("unexpected client counters %+v", s)
+	}
+	if s.Items != 1 {
+		t.Errorf("Expected DBSIZE of 1, got %d", s.Items)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	info := "# Stats\r\nexpired_keys:12\r\nevicted_keys:3\r\nrole:master\r\n# Memory\r\nused_memory:1024\r\n"
+	fields := parseInfo(info)
+	if fields["expired_keys"] != 12 || fields["evicted_keys"] != 3 || fields["used_memory"] != 1024 {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	if _, ok := fields["role"]; ok {
+		t.Errorf("Expected non-numeric fields to be skipped")
+	}
+}
+
+// the invalidation script deletes big tags in chunks
+func TestInvalidateLargeTag(t *testing.T) {
+	c, mr := newTestCacheStructure(t)
+	for i := 0; i < 2500; i++ {
+		if err := c.SetWithTags(fmt.Sprintf("k%d", i), i, 0, "big"); err != nil {
+			t.Fatalf("SetWithTags failed: %v", err)
+		}
+	}
+	if err := c.InvalidateTag("big"); err != nil {
+		t.Fatalf("InvalidateTag failed: %v", err)
+	}
+	if keys := mr.DB(10).Keys(); len(keys) != 0 {
+		t.Errorf("Expected no keys left, got %d", len(keys))
+	}
+}
+
+// a tag set expires with its longest lived member, not before
+func TestTagSetExpiry(t *testing.T) {
+	c, mr := newTestCacheStructure(t)
+	mr.Select(10)
+	set := tagPrefix + "page"
+	c.SetWithTags("a", "v", time.Minute, "page")
+	c.SetWithTags("b", "v", 10*time.Second, "page")
+	if ttl := mr.TTL(set); ttl != time.Minute {
+		t.Errorf("Expected the set to live a minute, got %v", ttl)
+	}
+	c.SetWithTags("c", "v", 2*time.Minute, "page")
+	if ttl := mr.TTL(set); ttl != 2*time.Minute {
+		t.Errorf("Expected the set to live two minutes, got %v", ttl)
+	}
+	mr.FastForward(3 * time.Minute)
+	if mr.Exists(set) {
+		t.Error("Expected the set to expire with its members")
+	}
+
+	// a member without a ttl keeps the set
+	c.SetWithTags("d", "v", time.Minute, "page")
+	c.SetWithTags("e", "v", 0, "page")
+	c.SetWithTags("f", "v", time.Minute, "page")
+	mr.FastForward(2 * time.Minute)
+	if !mr.Exists(set) {
+		t.Error("Expected the set to outlive its members with a ttl")
+	}
+	if err := c.InvalidateTag("page"); err != nil {
+		t.Fatalf("InvalidateTag failed: %v", err)
+	}
+	if _, err := c.Get("e"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected e gone after InvalidateTag, got %v", err)
+	}
+}
+
+func TestComplianceNamespace(t *testing.T) {
+	compliance.RunTest(t, func(t *testing.T) (cache.Cache, func(time.Duration)) {
+		_, mr := newTestCacheStructure(t)
+		c, err := NewRedisCache(RedisConfig{Addr: mr.Addr(), DB: 10, Namespace: "svc"})
+		if err != nil {
+			t.Fatalf("failed to create redis cache: %v", err)
+		}
+		return c, func(d time.Duration) {
+			mr.FastForward(d)
+		}
+	})
+}
+
+// Clear only touches the keys of its own namespace
+func TestNamespaceClear(t *testing.T) {
+	plain, mr := newTestCacheStructure(t)
+	open := func(ns string) *RedisCache {
+		c, err := NewRedisCache(RedisConfig{Addr: mr.Addr(), DB: 10, Namespace: ns})
+		if err != nil {
+			t.Fatalf("failed to create redis cache: %v", err)
+		}
+		return c
+	}
+	a, b, glob := open("a"), open("b"), open("a*")
+
+	plain.Set("k", "plain")
+	for i := 0; i < 1200; i++ {
+		a.Set(fmt.Sprintf("k%d", i), i)
+	}
+	a.SetWithTags("tagged", 1, 0, "t")
+	b.Set("k", "b")
+	if !mr.DB(10).Exists("a:k0") || !mr.DB(10).Exists("b:k") {
+		t.Fatal("Expected keys to carry the namespace prefix")
+	}
+
+	// "a*" must not match the keys of "a"
+	if err := glob.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if _, err := a.Get("k0"); err != nil {
+		t.Errorf("Expected a:k0 to survive clearing a*, got %v", err)
+	}
+
+	if err := a.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if _, err := a.Get("k0"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected a:k0 cleared, got %v", err)
+	}
+	if n := len(mr.DB(10).Keys()); n != 2 {
+		t.Errorf("Expected only the plain and b keys left, got %d keys", n)
+	}
+	if val, _ := b.Get("k"); val != "b" {
+		t.Errorf("Expected b:k to survive, got %v", val)
+	}
+	if val, _ := plain.Get("k"); val != "plain" {
+		t.Errorf("Expected plain k to survive, got %v", val)
+	}
+}
+
+// "team" would cover "team:billing" if the separator were allowed
+func TestNestedNamespace(t *testing.T) {
+	_, mr := newTestCacheStructure(t)
+	if _, err := NewRedisCache(RedisConfig{Addr: mr.Addr(), Namespace: "team:billing"}); !errors.Is(err, ErrInvalidNamespace) {
+		t.Errorf("Expected ErrInvalidNamespace, got %v", err)
+	}
+}
+
+// a namespace only lists its own keys, without the tag bookkeeping
+func TestKeysNamespace(t *testing.T) {
+	plain, mr := newTestCacheStructure(t)
+	c, err := NewRedisCache(RedisConfig{Addr: mr.Addr(), DB: 10, Namespace: "svc"})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	plain.Set("user:0", "other")
+	c.SetWithTags("user:1", "ana", 0, "users")
+	keys := slices.Collect(c.Keys(""))
+	if !slices.Equal(keys, []string{"user:1"}) {
+		t.Errorf("Expected [user:1], got %v", keys)
+	}
+}
+
+// held locks are neither listed nor decoded as values
+func TestIterationSkipsLocks(t *testing.T) {
+	c, _ := newTestCacheStructure(t)
+	ctx := context.Background()
+	c.Set("user:1", "ana")
+	if _, _, err := c.TryLock(ctx, "job", "owner", time.Minute); err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	if keys := slices.Collect(c.Keys("")); !slices.Equal(keys, []string{"user:1"}) {
+		t.Errorf("Expected [user:1], got %v", keys)
+	}
+	got := map[string]interface{}{}
+	for key, val := range c.Range("") {
+		got[key] = val
+	}
+	if len(got) != 1 || got["user:1"] != "ana" {
+		t.Errorf("Expected only user:1 from Range, got %v", got)
+	}
+}
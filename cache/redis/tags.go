@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"Go-library/cache"
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tagPrefix marks the sets holding the keys of a tag, keys starting with
+// it are reserved.
+const tagPrefix = "__tag:"
+
+var _ cache.TagCache = (*RedisCache)(nil)
+
+// deletes the members of a tag set and the set itself in one step, so a
+// key tagged meanwhile is not lost. DEL takes the members in chunks to stay
+// below lua's unpack limit.
+var invalidateTagScript = redis.NewScript(`
+local keys = redis.call('SMEMBERS', KEYS[1])
+local deleted = 0
+for i = 1, #keys, 1000 do
+	deleted = deleted + redis.call('DEL', unpack(keys, i, math.min(i + 999, #keys)))
+end
+redis.call('DEL', KEYS[1])
+return deleted
+`)
+
+// stores the value in KEYS[1] with a ttl of ARGV[2] ms, 0 for none, and
+// adds it to the tag sets in KEYS[2..]. a set lives at least as long as its
+// members: its expiry is only ever pushed back, and a member without a ttl
+// removes it.
+var setWithTagsScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+for i = 2, #KEYS do
+	local fresh = redis.call('EXISTS', KEYS[i]) == 0
+	redis.call('SADD', KEYS[i], KEYS[1])
+	if ttl == 0 then
+		redis.call('PERSIST', KEYS[i])
+	elseif fresh then
+		redis.call('PEXPIRE', KEYS[i], ttl)
+	else
+		local left = redis.call('PTTL', KEYS[i])
+		if left >= 0 and left < ttl then
+			redis.call('PEXPIRE', KEYS[i], ttl)
+		end
+	end
+end
+return 1
+`)
+
+// SetWithTags stores the value and adds key to the set of every tag in one
+// script. a ttl of 0 means the key never expires. a tag set expires once
+// its last member with a ttl would have, and never while it holds a key
+// without one.
+func (c *RedisCache) SetWithTags(key string, value interface{}, ttl time.Duration, tags ...string) error {
+	return c.SetWithTagsCtx(context.Background(), key, value, ttl, tags...)
+}
+
+// SetWithTagsCtx is SetWithTags bounded by ctx.
+func (c *RedisCache) SetWithTagsCtx(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	if key == "" {
+		return cache.ErrEmptyKey
+	}
+	for _, tag := range tags {
+		if tag == "" {
+			return cache.ErrEmptyTag
+		}
+	}
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, 1+len(tags))
+	keys = append(keys, c.key(key))
+	for _, tag := range tags {
+		keys = append(keys, c.key(tagPrefix+tag))
+	}
+	var ms int64
+	if ttl > 0 {
+		ms = max(ttl.Milliseconds(), 1)
+	}
+	if err := setWithTagsScript.Run(ctx, c.client, keys, data, ms).Err(); err != nil {
+		return err
+	}
+	c.stats.AddSets(1)
+	return nil
+}
+
+// InvalidateTag deletes every key in the tag's set and the set.
+func (c *RedisCache) InvalidateTag(tag string) error {
+	return c.InvalidateTagCtx(context.Background(), tag)
+}
+
+// InvalidateTagCtx is InvalidateTag bounded by ctx.
+func (c *RedisCache) InvalidateTagCtx(ctx context.Context, tag string) error {
+	if tag == "" {
+		return cache.ErrEmptyTag
+	}
+	deleted, err := invalidateTagScript.Run(ctx, c.client, []string{c.key(tagPrefix + tag)}).Int64()
+	if err != nil {
+		return err
+	}
+	c.stats.AddDeletes(deleted)
+	return nil
+}
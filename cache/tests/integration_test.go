@@ -13,13 +13,15 @@ func TestUnifiedAPI(t *testing.T) {
 	// test in-memroy backend tests
 	t.Run("Memory", func(t *testing.T) {
 		compliance.RunTest(t, func(t *testing.T) (cache.Cache, func(time.Duration)) {
+			clock := cache.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
 			c, err := factory.New(factory.Memory, factory.Config{
 				MemoryMaxSize: 100,
+				MemoryClock:   clock,
 			})
 			if err != nil {
 				t.Fatalf("Failed to create memory cache: %v", err)
 			}
-			return c, nil
+			return c, clock.Advance
 		})
 	})
 
@@ -76,3 +78,49 @@ func TestUnifiedAPI(t *testing.T) {
 		})
 	})
 }
+
+// typed values must round-trip the same way on every backend built by the factory
+func TestTypedUnified(t *testing.T) {
+	type item struct {
+		ID    int
+		Label string
+	}
+	backends := map[string]func() (cache.Cache, error){
+		"Memory": func() (cache.Cache, error) {
+			return factory.New(factory.Memory, factory.Config{})
+		},
+		"Redis": func() (cache.Cache, error) {
+			return factory.New(factory.Redis, factory.Config{RedisAddr: "localhost:6380"})
+		},
+		"Memcached": func() (cache.Cache, error) {
+			c, err := factory.New(factory.Memcached, factory.Config{
+				MemcachedServers: []string{"localhost:11211"},
+			})
+			if err != nil {
+				return nil, err
+			}
+			return c, c.Set("ping", "pong")
+		},
+	}
+	for name, newCache := range backends {
+		t.Run(name, func(t *testing.T) {
+			c, err := newCache()
+			if err != nil {
+				t.Skipf("Skipping %s: %v", name, err)
+			}
+			tc := cache.NewTyped[item](c)
+			want := item{ID: 7, Label: "seven"}
+			if err := tc.SetWithTTL("typed:item", want, time.Minute); err != nil {
+				t.Fatalf("SetWithTTL failed: %v", err)
+			}
+			got, err := tc.Get("typed:item")
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if got != want {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+			_ = tc.Delete("typed:item")
+		})
+	}
+}
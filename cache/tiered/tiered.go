@@ -0,0 +1,370 @@
+package tiered
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/codec"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultL1TTL bounds how long a value is served from L1 without asking L2.
+const DefaultL1TTL = time.Minute
+
+// Cache is a two level cache, a local L1 (usually a memory.Memorycache) in
+// front of a remote L2 such as redis. reads are served from L1 and fall back
+// to L2, writes go through to L2 first and then to L1.
+//
+// L1 keeps a value for at most the L1 TTL, so changes made to L2 by other
+// processes show up after that at the latest. when L2 has a codec, L1 holds
+// the value as L2 would decode it, so both tiers return the same types.
+type Cache struct {
+	l1    cache.Cache
+	l2    cache.Cache
+	l1TTL time.Duration
+	codec codec.Codec // nil when L2 keeps values as they are
+
+	invMu sync.RWMutex
+	inv   Invalidator
+
+	// guard the L1 copies, a fill from L2 is dropped when the key was
+	// written or invalidated while L2 was read
+	stripes [stripeCount]stripe
+}
+
+const stripeCount = 64
+
+type stripe struct {
+	mu  sync.Mutex
+	gen uint64
+}
+
+// Invalidator carries invalidations between the processes sharing an L2,
+// e.g. redis.InvalidationBus.
+type Invalidator interface {
+	// Publish asks the other processes to drop keys, all of them when
+	// no keys are given
+	Publish(ctx context.Context, keys ...string) error
+	// Subscribe calls fn for invalidations published by other processes,
+	// keys is nil when everything has to go
+	Subscribe(fn func(keys []string))
+}
+
+var _ cache.Cache = (*Cache)(nil)
+var _ cache.ContextCache = (*Cache)(nil)
+var _ cache.CapabilityProvider = (*Cache)(nil)
+
+// New fronts l2 with l1. l1TTL <= 0 uses DefaultL1TTL.
+func New(l1, l2 cache.Cache, l1TTL time.Duration) *Cache {
+	if l1TTL <= 0 {
+		l1TTL = DefaultL1TTL
+	}
+	c := &Cache{l1: l1, l2: l2, l1TTL: l1TTL}
+	if cp, ok := l2.(interface{ Codec() codec.Codec }); ok {
+		c.codec = cp.Codec()
+	}
+	return c
+}
+
+// stripe returns the stripe guarding key's L1 copy.
+func (c *Cache) stripe(key string) *stripe {
+	// fnv-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &c.stripes[h%stripeCount]
+}
+
+// change runs fn on L1 for key and marks the key as changed, so a fill that
+// read L2 before is dropped.
+func (c *Cache) change(key string, fn func() error) error {
+	st := c.stripe(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.gen++
+	return fn()
+}
+
+// clearL1 empties L1 and marks every key as changed.
+func (c *Cache) clearL1() error {
+	for i := range c.stripes {
+		c.stripes[i].mu.Lock()
+		c.stripes[i].gen++
+	}
+	defer func() {
+		for i := range c.stripes {
+			c.stripes[i].mu.Unlock()
+		}
+	}()
+	return c.l1.Clear()
+}
+
+// local returns value as L2 would give it back.
+func (c *Cache) local(value interface{}) (interface{}, error) {
+	if c.codec == nil {
+		return value, nil
+	}
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := c.codec.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// setL1 stores the L1 copy of a value just written to L2. when L1 cannot
+// take it (too large, say) the old copy is dropped, the write itself landed.
+func (c *Cache) setL1(key string, value interface{}, ttl time.Duration) {
+	c.change(key, func() error {
+		val, err := c.local(value)
+		if err == nil {
+			err = c.l1.SetWithTTL(key, val, ttl)
+		}
+		if err != nil {
+			c.l1.Delete(key)
+		}
+		return nil
+	})
+}
+
+// deleteL1 drops the L1 copy of key.
+func (c *Cache) deleteL1(key string) error {
+	return c.change(key, func() error {
+		return c.l1.Delete(key)
+	})
+}
+
+// SetInvalidator makes writes publish the changed keys on inv and drops
+// the L1 copies other processes invalidate. without one, other processes
+// see a change once their L1 copy expires.
+func (c *Cache) SetInvalidator(inv Invalidator) {
+	c.invMu.Lock()
+	c.inv = inv
+	c.invMu.Unlock()
+	inv.Subscribe(func(keys []string) {
+		if keys == nil {
+			c.clearL1()
+			return
+		}
+		for _, key := range keys {
+			c.deleteL1(key)
+		}
+	})
+}
+
+// Close closes the invalidator and L1 when they can be closed.
+func (c *Cache) Close() error {
+	c.invMu.RLock()
+	inv := c.inv
+	c.invMu.RUnlock()
+	var err error
+	if cl, ok := inv.(io.Closer); ok {
+		err = cl.Close()
+	}
+	if cl, ok := c.l1.(io.Closer); ok {
+		if cerr := cl.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Capabilities: the context api, the rest depends on the tiers.
+func (c *Cache) Capabilities() cache.Capabilities {
+	l1, l2 := cache.CapabilitiesOf(c.l1), cache.CapabilitiesOf(c.l2)
+	return cache.Capabilities{
+		AnyValue:    l1.AnyValue && l2.AnyValue,
+		Context:     true,
+		Distributed: l2.Distributed,
+		ScopedClear: l2.ScopedClear,
+	}
+}
+
+// L1 returns the local tier.
+func (c *Cache) L1() cache.Cache {
+	return c.l1
+}
+
+// L2 returns the remote tier.
+func (c *Cache) L2() cache.Cache {
+	return c.l2
+}
+
+func (c *Cache) Set(key string, value interface{}) error {
+	return c.SetCtx(context.Background(), key, value)
+}
+
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.SetWithTTLCtx(context.Background(), key, value, ttl)
+}
+
+func (c *Cache) Get(key string) (interface{}, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+func (c *Cache) Delete(key string) error {
+	return c.DeleteCtx(context.Background(), key)
+}
+
+func (c *Cache) Clear() error {
+	return c.ClearCtx(context.Background())
+}
+
+func (c *Cache) SetCtx(ctx context.Context, key string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := setCtx(ctx, c.l2, key, value, 0); err != nil {
+		// L1 may still hold the old value, drop it rather than serve it
+		c.deleteL1(key)
+		return err
+	}
+	c.setL1(key, value, c.l1TTL)
+	return c.publish(ctx, key)
+}
+
+func (c *Cache) SetWithTTLCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := setCtx(ctx, c.l2, key, value, ttl); err != nil {
+		c.deleteL1(key)
+		return err
+	}
+	l1TTL := c.l1TTL
+	if ttl > 0 {
+		l1TTL = min(ttl, l1TTL)
+	}
+	c.setL1(key, value, l1TTL)
+	return c.publish(ctx, key)
+}
+
+// GetCtx fills L1 on an L2 hit, unless the key was written or invalidated
+// during the L2 read. the remaining TTL in L2 is unknown here, so the L1
+// copy gets the L1 TTL.
+func (c *Cache) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if val, err := c.l1.Get(key); err == nil || err == cache.ErrEmptyKey {
+		return val, err
+	}
+	st := c.stripe(key)
+	st.mu.Lock()
+	gen := st.gen
+	st.mu.Unlock()
+	val, err := getCtx(ctx, c.l2, key)
+	if err != nil {
+		return nil, err
+	}
+	st.mu.Lock()
+	if st.gen == gen {
+		c.l1.SetWithTTL(key, val, c.l1TTL)
+	}
+	st.mu.Unlock()
+	return val, nil
+}
+
+// DeleteCtx removes key from both tiers, ErrKeyNotFound reflects L2.
+func (c *Cache) DeleteCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.deleteL1(key); err == cache.ErrEmptyKey {
+		return err
+	}
+	err := deleteCtx(ctx, c.l2, key)
+	if err != nil && err != cache.ErrKeyNotFound {
+		return err
+	}
+	// a read that raced the L2 delete may have filled L1 again
+	c.deleteL1(key)
+	// other processes may hold a copy even when L2 no longer has the key
+	if perr := c.publish(ctx, key); perr != nil {
+		return perr
+	}
+	return err
+}
+
+func (c *Cache) ClearCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.clearL1(); err != nil {
+		return err
+	}
+	if err := clearCtx(ctx, c.l2); err != nil {
+		return err
+	}
+	if err := c.clearL1(); err != nil {
+		return err
+	}
+	return c.publish(ctx)
+}
+
+// publish reports a change to the other processes. the write itself has
+// succeeded when this fails, the error says their L1 may be stale.
+func (c *Cache) publish(ctx context.Context, keys ...string) error {
+	c.invMu.RLock()
+	inv := c.inv
+	c.invMu.RUnlock()
+	if inv == nil {
+		return nil
+	}
+	return inv.Publish(ctx, keys...)
+}
+
+// the tiers only have to be a cache.Cache, use their context methods when
+// they have them.
+
+func setCtx(ctx context.Context, c cache.Cache, key string, value interface{}, ttl time.Duration) error {
+	if cc, ok := c.(cache.ContextCache); ok {
+		if ttl > 0 {
+			return cc.SetWithTTLCtx(ctx, key, value, ttl)
+		}
+		return cc.SetCtx(ctx, key, value)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ttl > 0 {
+		return c.SetWithTTL(key, value, ttl)
+	}
+	return c.Set(key, value)
+}
+
+func getCtx(ctx context.Context, c cache.Cache, key string) (interface{}, error) {
+	if cc, ok := c.(cache.ContextCache); ok {
+		return cc.GetCtx(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Get(key)
+}
+
+func deleteCtx(ctx context.Context, c cache.Cache, key string) error {
+	if cc, ok := c.(cache.ContextCache); ok {
+		return cc.DeleteCtx(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Delete(key)
+}
+
+func clearCtx(ctx context.Context, c cache.Cache) error {
+	if cc, ok := c.(cache.ContextCache); ok {
+		return cc.ClearCtx(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Clear()
+}
@@ -0,0 +1,256 @@
+package tiered
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/compliance"
+	"Go-library/cache/cache/memory"
+	"Go-library/cache/cache/redis"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestTiered(t *testing.T, l1TTL time.Duration) (*Cache, *redis.RedisCache, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	rc, err := redis.NewRedisCache(redis.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	return New(memory.NewMemorycache(), rc, l1TTL), rc, mr
+}
+
+// l1Clock puts c's memory L1 on a fake clock.
+func l1Clock(c *Cache) *cache.FakeClock {
+	clock := cache.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.L1().(*memory.Memorycache).SetClock(clock)
+	return clock
+}
+
+func TestCompliance(t *testing.T) {
+	compliance.RunTest(t, func(t *testing.T) (cache.Cache, func(time.Duration)) {
+		c, _, mr := newTestTiered(t, 0)
+		// L1 runs on a fake clock, L2 on miniredis' one
+		clock := l1Clock(c)
+		return c, func(d time.Duration) {
+			mr.FastForward(d)
+			clock.Advance(d)
+		}
+	})
+}
+
+func TestWriteThrough(t *testing.T) {
+	c, rc, _ := newTestTiered(t, time.Minute)
+	if err := c.Set("k", "v"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if val, err := c.L1().Get("k"); err != nil || val != "v" {
+		t.Errorf("Expected k in L1, got %v, %v", val, err)
+	}
+	if val, err := rc.Get("k"); err != nil || val != "v" {
+		t.Errorf("Expected k in L2, got %v, %v", val, err)
+	}
+}
+
+func TestReadFillsL1(t *testing.T) {
+	c, rc, _ := newTestTiered(t, time.Minute)
+	rc.Set("k", "v")
+	if val, err := c.Get("k"); err != nil || val != "v" {
+		t.Fatalf("Expected k from L2, got %v, %v", val, err)
+	}
+	if val, err := c.L1().Get("k"); err != nil || val != "v" {
+		t.Errorf("Expected L1 filled, got %v, %v", val, err)
+	}
+}
+
+// a change made to L2 behind our back shows up once the L1 copy expires
+func TestL1TTL(t *testing.T) {
+	c, rc, _ := newTestTiered(t, 50*time.Millisecond)
+	clock := l1Clock(c)
+	c.Set("k", "old")
+	rc.Set("k", "new")
+	if val, _ := c.Get("k"); val != "old" {
+		t.Errorf("Expected L1 copy 'old', got %v", val)
+	}
+	clock.Advance(100 * time.Millisecond)
+	if val, _ := c.Get("k"); val != "new" {
+		t.Errorf("Expected 'new' after L1 TTL, got %v", val)
+	}
+}
+
+// the L1 copy never outlives the TTL given to SetWithTTL
+func TestShortTTLWins(t *testing.T) {
+	c, _, mr := newTestTiered(t, time.Minute)
+	clock := l1Clock(c)
+	c.SetWithTTL("k", "v", 50*time.Millisecond)
+	mr.FastForward(100 * time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
+	if _, err := c.Get("k"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+type failingCache struct{ cache.Cache }
+
+var errDown = errors.New("l2 down")
+
+func (failingCache) Set(string, interface{}) error { return errDown }
+
+func TestL2FailureDropsL1(t *testing.T) {
+	l1 := memory.NewMemorycache()
+	l1.Set("k", "stale")
+	c := New(l1, failingCache{memory.NewMemorycache()}, time.Minute)
+	if err := c.Set("k", "v"); err != errDown {
+		t.Fatalf("Expected L2 error, got %v", err)
+	}
+	if _, err := l1.Get("k"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected L1 copy dropped, got %v", err)
+	}
+}
+
+// both tiers give back what the L2 codec decodes
+func TestSameTypesFromBothTiers(t *testing.T) {
+	c, _, _ := newTestTiered(t, time.Minute)
+	c.Set("n", 42)
+	fromL1, err := c.Get("n")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	c.L1().Clear()
+	fromL2, err := c.Get("n")
+	if err != nil {
+		t.Fatalf("Get from L2 failed: %v", err)
+	}
+	if fromL1 != fromL2 {
+		t.Errorf("Expected the same value from both tiers, got %T %v and %T %v", fromL1, fromL1, fromL2, fromL2)
+	}
+}
+
+// runs hook after reading from the cache it wraps
+type hookCache struct {
+	cache.Cache
+	hook func()
+}
+
+func (h hookCache) Get(key string) (interface{}, error) {
+	val, err := h.Cache.Get(key)
+	h.hook()
+	return val, err
+}
+
+// a write or invalidation during the L2 read keeps the old value out of L1
+func TestFillRacingWrite(t *testing.T) {
+	l2 := memory.NewMemorycache()
+	hook := func() {}
+	c := New(memory.NewMemorycache(), hookCache{l2, func() { hook() }}, time.Minute)
+	l2.Set("k", "old")
+	hook = func() { c.Set("k", "new") }
+	c.Get("k")
+	if val, err := c.L1().Get("k"); err != nil || val != "new" {
+		t.Errorf("Expected 'new' in L1, got %v, %v", val, err)
+	}
+
+	l2.Set("d", "old")
+	hook = func() { c.Delete("d") }
+	c.Get("d")
+	if val, err := c.L1().Get("d"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected no L1 copy of d, got %v, %v", val, err)
+	}
+}
+
+// a value L1 cannot hold replaces the old copy there by nothing
+func TestL1RejectDropsCopy(t *testing.T) {
+	l1 := memory.NewMemorycache()
+	l1.SetMaxCost(100)
+	l2 := memory.NewMemorycache()
+	c := New(l1, l2, time.Minute)
+	c.Set("k", "small")
+	big := strings.Repeat("x", 500)
+	if err := c.Set("k", big); err != nil {
+		t.Fatalf("Expected the L2 write to count, got %v", err)
+	}
+	if _, err := l1.Get("k"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected the old L1 copy dropped, got %v", err)
+	}
+	if val, err := c.Get("k"); err != nil || val != big {
+		t.Errorf("Expected the big value from L2, got %v, %v", val, err)
+	}
+}
+
+func TestDeleteBothTiers(t *testing.T) {
+	c, rc, _ := newTestTiered(t, time.Minute)
+	c.Set("k", "v")
+	if err := c.Delete("k"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := c.L1().Get("k"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected k gone from L1, got %v", err)
+	}
+	if _, err := rc.Get("k"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected k gone from L2, got %v", err)
+	}
+}
+
+// a write on one process drops the L1 copies of the others
+func TestInvalidator(t *testing.T) {
+	a, rcA, mr := newTestTiered(t, time.Minute)
+	rcB, err := redis.NewRedisCache(redis.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	b := New(memory.NewMemorycache(), rcB, time.Minute)
+	for _, pair := range []struct {
+		c  *Cache
+		rc *redis.RedisCache
+	}{{a, rcA}, {b, rcB}} {
+		bus, err := redis.NewInvalidationBus(pair.rc, "invalidations")
+		if err != nil {
+			t.Fatalf("failed to create bus: %v", err)
+		}
+		pair.c.SetInvalidator(bus)
+		t.Cleanup(func() { pair.c.Close() })
+	}
+
+	a.Set("k", "old")
+	b.Get("k") // b now has an L1 copy
+	a.Set("k", "new")
+	waitFor(t, func() bool {
+		_, err := b.L1().Get("k")
+		return err == cache.ErrKeyNotFound
+	})
+	if val, _ := b.Get("k"); val != "new" {
+		t.Errorf("Expected 'new', got %v", val)
+	}
+
+	a.Delete("k")
+	waitFor(t, func() bool {
+		_, err := b.L1().Get("k")
+		return err == cache.ErrKeyNotFound
+	})
+
+	b.Set("x", 1)
+	a.Get("x")
+	b.Clear()
+	waitFor(t, func() bool {
+		_, err := a.L1().Get("x")
+		return err == cache.ErrKeyNotFound
+	})
+}
+
+// invalidations arrive asynchronously
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
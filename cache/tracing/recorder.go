@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// Recorder is a Tracer that keeps finished spans in memory, for tests.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// RecordedSpan is a span captured by Recorder.
+type RecordedSpan struct {
+	Name       string
+	Parent     *RecordedSpan
+	Attributes map[string]interface{}
+	Err        error
+
+	rec   *Recorder
+	ended bool
+}
+
+type spanKey struct{}
+
+// Start begins a span, a span already in ctx becomes its parent.
+func (r *Recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(*RecordedSpan)
+	s := &RecordedSpan{Name: name, Parent: parent, Attributes: make(map[string]interface{}), rec: r}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Spans returns the ended spans in the order they ended.
+func (r *Recorder) Spans() []*RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*RecordedSpan(nil), r.spans...)
+}
+
+// Reset forgets the recorded spans.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+func (s *RecordedSpan) SetAttributes(attrs ...Attribute) {
+	for _, a := range attrs {
+		s.Attributes[a.Key] = a.Value
+	}
+}
+
+func (s *RecordedSpan) RecordError(err error) {
+	s.Err = err
+}
+
+// End records the span, ending it twice has no effect.
+func (s *RecordedSpan) End() {
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.ended = true
+	s.rec.spans = append(s.rec.spans, s)
+}
@@ -0,0 +1,189 @@
+// Package tracing wraps caches so every call produces a span. the Tracer and
+// Span interfaces follow the OpenTelemetry shape, so an adapter over an
+// OpenTelemetry tracer is a few lines, and Recorder keeps spans in memory
+// for tests.
+package tracing
+
+import (
+	"Go-library/cache"
+	"context"
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+// Attribute is a key/value pair attached to a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a unit of traced work.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// RecordError marks the span as failed.
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans, the returned context carries the new span.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// attribute keys set on every cache span
+const (
+	AttrBackend = "cache.backend"
+	AttrKeyHash = "cache.key_hash" // fnv-1a of the key, keys themselves may be sensitive
+	AttrHit     = "cache.hit"      // Get and Delete only
+	AttrTTL     = "cache.ttl_ms"   // SetWithTTL only
+)
+
+// Cache emits a span around each call of the wrapped cache. it implements
+// both cache.Cache and cache.ContextCache, the latter passing the span's
+// context down when the wrapped cache is context-aware too.
+type Cache struct {
+	next    cache.Cache
+	tracer  Tracer
+	backend string
+}
+
+var _ cache.Cache = (*Cache)(nil)
+var _ cache.ContextCache = (*Cache)(nil)
+
+// Wrap traces c. backend names the backend type, e.g. string(factory.Redis).
+func Wrap(c cache.Cache, tracer Tracer, backend string) *Cache {
+	return &Cache{next: c, tracer: tracer, backend: backend}
+}
+
+// Middleware is Wrap as a cache.Middleware, for use with cache.Chain.
+func Middleware(tracer Tracer, backend string) cache.Middleware {
+	return func(next cache.Cache) cache.Cache {
+		return Wrap(next, tracer, backend)
+	}
+}
+
+// Unwrap returns the traced cache.
+func (c *Cache) Unwrap() cache.Cache {
+	return c.next
+}
+
+func (c *Cache) Set(key string, value interface{}) error {
+	return c.SetCtx(context.Background(), key, value)
+}
+
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return c.SetWithTTLCtx(context.Background(), key, value, ttl)
+}
+
+func (c *Cache) Get(key string) (interface{}, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+func (c *Cache) Delete(key string) error {
+	return c.DeleteCtx(context.Background(), key)
+}
+
+func (c *Cache) Clear() error {
+	return c.ClearCtx(context.Background())
+}
+
+func (c *Cache) SetCtx(ctx context.Context, key string, value interface{}) error {
+	ctx, span := c.start(ctx, "cache.set", key)
+	defer span.End()
+	var err error
+	if cc, ok := c.next.(cache.ContextCache); ok {
+		err = cc.SetCtx(ctx, key, value)
+	} else if err = ctx.Err(); err == nil {
+		err = c.next.Set(key, value)
+	}
+	record(span, err)
+	return err
+}
+
+func (c *Cache) SetWithTTLCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	ctx, span := c.start(ctx, "cache.set_with_ttl", key)
+	defer span.End()
+	span.SetAttributes(Attribute{AttrTTL, ttl.Milliseconds()})
+	var err error
+	if cc, ok := c.next.(cache.ContextCache); ok {
+		err = cc.SetWithTTLCtx(ctx, key, value, ttl)
+	} else if err = ctx.Err(); err == nil {
+		err = c.next.SetWithTTL(key, value, ttl)
+	}
+	record(span, err)
+	return err
+}
+
+func (c *Cache) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	ctx, span := c.start(ctx, "cache.get", key)
+	defer span.End()
+	var (
+		val interface{}
+		err error
+	)
+	if cc, ok := c.next.(cache.ContextCache); ok {
+		val, err = cc.GetCtx(ctx, key)
+	} else if err = ctx.Err(); err == nil {
+		val, err = c.next.Get(key)
+	}
+	recordLookup(span, err)
+	return val, err
+}
+
+func (c *Cache) DeleteCtx(ctx context.Context, key string) error {
+	ctx, span := c.start(ctx, "cache.delete", key)
+	defer span.End()
+	var err error
+	if cc, ok := c.next.(cache.ContextCache); ok {
+		err = cc.DeleteCtx(ctx, key)
+	} else if err = ctx.Err(); err == nil {
+		err = c.next.Delete(key)
+	}
+	recordLookup(span, err)
+	return err
+}
+
+func (c *Cache) ClearCtx(ctx context.Context) error {
+	ctx, span := c.tracer.Start(ctx, "cache.clear")
+	defer span.End()
+	span.SetAttributes(Attribute{AttrBackend, c.backend})
+	var err error
+	if cc, ok := c.next.(cache.ContextCache); ok {
+		err = cc.ClearCtx(ctx)
+	} else if err = ctx.Err(); err == nil {
+		err = c.next.Clear()
+	}
+	record(span, err)
+	return err
+}
+
+func (c *Cache) start(ctx context.Context, name, key string) (context.Context, Span) {
+	ctx, span := c.tracer.Start(ctx, name)
+	span.SetAttributes(
+		Attribute{AttrBackend, c.backend},
+		Attribute{AttrKeyHash, KeyHash(key)},
+	)
+	return ctx, span
+}
+
+// KeyHash is the value of the cache.key_hash attribute for key.
+func KeyHash(key string) string {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+func record(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+}
+
+// a missing key is a miss, not a failure
+func recordLookup(span Span, err error) {
+	span.SetAttributes(Attribute{AttrHit, err == nil})
+	if err != cache.ErrKeyNotFound {
+		record(span, err)
+	}
+}
@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/compliance"
+	"Go-library/cache/cache/memory"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// the wrapper must not change what the cache does
+func TestCompliance(t *testing.T) {
+	compliance.RunTest(t, func(t *testing.T) (cache.Cache, func(time.Duration)) {
+		mc := memory.NewMemorycache()
+		clock := cache.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		mc.SetClock(clock)
+		return Wrap(mc, &Recorder{}, "memory"), clock.Advance
+	})
+}
+
+func TestSpans(t *testing.T) {
+	rec := &Recorder{}
+	c := Wrap(memory.NewMemorycache(), rec, "memory")
+
+	c.SetWithTTL("user:1", "ana", time.Minute)
+	c.Get("user:1")
+	c.Get("user:2")
+	c.Delete("user:2")
+	c.Clear()
+
+	spans := rec.Spans()
+	if len(spans) != 5 {
+		t.Fatalf("Expected 5 spans, got %d", len(spans))
+	}
+	wantNames := []string{"cache.set_with_ttl", "cache.get", "cache.get", "cache.delete", "cache.clear"}
+	for i, s := range spans {
+		if s.Name != wantNames[i] {
+			t.Errorf("span %d: expected %s, got %s", i, wantNames[i], s.Name)
+		}
+		if s.Attributes[AttrBackend] != "memory" {
+			t.Errorf("span %d: expected backend attribute, got %v", i, s.Attributes)
+		}
+		if s.Err != nil {
+			t.Errorf("span %d: expected no error, got %v", i, s.Err)
+		}
+	}
+	if spans[0].Attributes[AttrKeyHash] != KeyHash("user:1") || spans[0].Attributes[AttrTTL] != int64(60000) {
+		t.Errorf("unexpected set attributes %v", spans[0].Attributes)
+	}
+	if spans[1].Attributes[AttrHit] != true || spans[2].Attributes[AttrHit] != false {
+		t.Errorf("Expected hit then miss, got %v and %v", spans[1].Attributes[AttrHit], spans[2].Attributes[AttrHit])
+	}
+	if _, ok := spans[4].Attributes[AttrKeyHash]; ok {
+		t.Errorf("Expected no key hash on clear")
+	}
+}
+
+func TestSpanErrors(t *testing.T) {
+	rec := &Recorder{}
+	c := Wrap(memory.NewMemorycache(), rec, "memory")
+
+	c.Set("", "x")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.GetCtx(ctx, "k")
+
+	spans := rec.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Err != cache.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey on span, got %v", spans[0].Err)
+	}
+	if !errors.Is(spans[1].Err, context.Canceled) {
+		t.Errorf("Expected context.Canceled on span, got %v", spans[1].Err)
+	}
+}
+
+// a span already in the context becomes the parent, also for caches that
+// are not context-aware themselves
+func TestParentSpan(t *testing.T) {
+	rec := &Recorder{}
+	c := Wrap(plainCache{memory.NewMemorycache()}, rec, "plain")
+
+	ctx, parent := rec.Start(context.Background(), "handler")
+	c.SetCtx(ctx, "k", "v")
+	parent.End()
+
+	spans := rec.Spans()
+	if len(spans) != 2 || spans[0].Parent != spans[1] {
+		t.Fatalf("Expected cache span to be a child of the handler span")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := c.SetCtx(cancelled, "k", "v"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for plain cache, got %v", err)
+	}
+}
+
+// plainCache hides the context-aware methods of the wrapped cache
+type plainCache struct{ cache.Cache }
+
+func TestMiddleware(t *testing.T) {
+	rec := &Recorder{}
+	c := cache.Chain(memory.NewMemorycache(), cache.WithPrefix("app:"), Middleware(rec, "memory"))
+	c.Set("k", "v")
+	spans := rec.Spans()
+	if len(spans) != 1 || spans[0].Attributes[AttrKeyHash] != KeyHash("app:k") {
+		t.Errorf("Expected one span for the prefixed key, got %+v", spans)
+	}
+}
@@ -0,0 +1,51 @@
+package cache
+
+// Capabilities says what a backend supports beyond the Cache interface, so
+// callers can check up front instead of failing at runtime.
+type Capabilities struct {
+	// any value can be stored, false when only some types are accepted
+	// (e.g. the raw codec takes []byte and string)
+	AnyValue bool
+	// the optional interfaces the backend implements
+	Context     bool // ContextCache
+	Batch       bool // BatchCache
+	Stats       bool // StatsProvider
+	Tags        bool // TagCache
+	Iteration   bool // IterableCache
+	Counters    bool // CounterCache
+	Conditional bool // ConditionalCache
+	// the data is shared with other processes
+	Distributed bool
+	// Clear removes only this cache's keys, not everything on the server
+	ScopedClear bool
+}
+
+// CapabilityProvider is implemented by backends that describe themselves.
+type CapabilityProvider interface {
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf describes c. caches without a Capabilities method are
+// probed for the optional interfaces; what cannot be probed is taken from
+// the cache they wrap (through Unwrap), or reported as false.
+func CapabilitiesOf(c Cache) Capabilities {
+	if p, ok := c.(CapabilityProvider); ok {
+		return p.Capabilities()
+	}
+	var caps Capabilities
+	if u, ok := c.(interface{ Unwrap() Cache }); ok {
+		inner := CapabilitiesOf(u.Unwrap())
+		caps.AnyValue = inner.AnyValue
+		caps.Distributed = inner.Distributed
+		caps.ScopedClear = inner.ScopedClear
+	}
+	_, caps.Context = c.(ContextCache)
+	_, caps.Batch = c.(BatchCache)
+	_, caps.Stats = c.(StatsProvider)
+	_, caps.Tags = c.(TagCache)
+	_, caps.Counters = c.(CounterCache)
+	_, caps.Conditional = c.(ConditionalCache)
+	_, err := Iterable(c)
+	caps.Iteration = err == nil
+	return caps
+}
@@ -0,0 +1,41 @@
+package cache_test
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/codec"
+	"Go-library/cache/cache/memcached"
+	"Go-library/cache/cache/memory"
+	"Go-library/cache/cache/metrics"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestCapabilitiesOf(t *testing.T) {
+	mc := memory.NewMemorycache()
+	if caps := cache.CapabilitiesOf(mc); !caps.AnyValue || !caps.Iteration || caps.Distributed {
+		t.Errorf("Unexpected memory capabilities %+v", caps)
+	}
+
+	// wrappers without a descriptor are probed, the rest comes from Unwrap
+	wrapped := metrics.NewRegistry().Wrap(mc, "memory", "test")
+	caps := cache.CapabilitiesOf(wrapped)
+	if !caps.AnyValue || !caps.Iteration || !caps.ScopedClear {
+		t.Errorf("Expected the wrapped cache's capabilities, got %+v", caps)
+	}
+	_, isBatch := cache.Cache(wrapped).(cache.BatchCache)
+	if caps.Batch != isBatch {
+		t.Errorf("Expected Batch=%v for the wrapper, got %v", isBatch, caps.Batch)
+	}
+
+	// no server is needed to describe memcached
+	m := memcached.New(memcache.New("localhost:11211"))
+	if caps := cache.CapabilitiesOf(m); !caps.AnyValue || caps.Iteration || !caps.Distributed || caps.ScopedClear {
+		t.Errorf("Unexpected memcached capabilities %+v", caps)
+	}
+	m.SetCodec(codec.Raw)
+	m.SetNamespace("svc")
+	if caps := cache.CapabilitiesOf(m); caps.AnyValue || !caps.ScopedClear {
+		t.Errorf("Expected raw values and a scoped Clear, got %+v", caps)
+	}
+}
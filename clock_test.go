@@ -0,0 +1,23 @@
+package cache_test
+
+import (
+	"Go-library/cache"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := cache.NewFakeClock(start)
+	if !c.Now().Equal(start) {
+		t.Fatalf("Expected %v, got %v", start, c.Now())
+	}
+	c.Advance(time.Minute)
+	if want := start.Add(time.Minute); !c.Now().Equal(want) {
+		t.Errorf("Expected %v after Advance, got %v", want, c.Now())
+	}
+	c.Set(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Expected %v after Set, got %v", start, c.Now())
+	}
+}
@@ -6,4 +6,15 @@ var (
 	ErrKeyNotFound = errors.New("key not found")
 	ErrEmptyKey    = errors.New("key is empty")
 	ErrKeyExpired  = errors.New("key has expired")
+	ErrEmptyTag    = errors.New("tag is empty")
+	// Add found the key already there
+	ErrKeyExists = errors.New("key already exists")
+	// Incr or Decr found a value that is not an integer
+	ErrNotInteger = errors.New("value is not an integer")
+	// a stored value could not be decoded into the requested type
+	ErrDecode = errors.New("cannot decode cached value")
+	// the backend cannot do what was asked, e.g. list its keys
+	ErrNotSupported = errors.New("not supported by this backend")
+	// a cache call panicked and was recovered by WithRecovery
+	ErrPanic = errors.New("cache call panicked")
 )
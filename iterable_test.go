@@ -0,0 +1,31 @@
+package cache_test
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/memcached"
+	"Go-library/cache/cache/memory"
+	"Go-library/cache/cache/metrics"
+	"errors"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestIterable(t *testing.T) {
+	mc := memory.NewMemorycache()
+	if ic, err := cache.Iterable(mc); err != nil || ic != cache.IterableCache(mc) {
+		t.Errorf("Expected the memory cache back, got %v, %v", ic, err)
+	}
+
+	// wrappers are looked through
+	wrapped := metrics.NewRegistry().Wrap(mc, "memory", "test")
+	if ic, err := cache.Iterable(wrapped); err != nil || ic != cache.IterableCache(mc) {
+		t.Errorf("Expected the wrapped memory cache, got %v, %v", ic, err)
+	}
+
+	// memcached cannot list keys, no server is needed to find out
+	_, err := cache.Iterable(memcached.New(memcache.New("localhost:11211")))
+	if !errors.Is(err, cache.ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported for memcached, got %v", err)
+	}
+}
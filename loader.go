@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LoadingCache adds read-through loading to any Cache.
+// concurrent misses for the same key share one loader call and its result.
+type LoadingCache struct {
+	Cache
+
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+// one in-flight loader call and everyone waiting on it
+type loadCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// the loader panicked, waiters get this while the panic goes on in the
+// caller. it wraps ErrPanic.
+var errLoaderPanicked = fmt.Errorf("%w: loader did not return", ErrPanic)
+
+// NewLoadingCache wraps c with GetOrLoad.
+func NewLoadingCache(c Cache) *LoadingCache {
+	return &LoadingCache{
+		Cache: c,
+		calls: make(map[string]*loadCall),
+	}
+}
+
+// GetOrLoad returns the cached value for key. on ErrKeyNotFound it runs loader
+// once for all concurrent callers, stores the result with ttl (0 means no
+// expiry) and hands it to every waiter. loader errors are shared but never
+// cached. if storing the loaded value fails the value is returned together
+// with the store error. if loader panics the panic goes on in the caller
+// that ran it, and the waiters get an error wrapping ErrPanic.
+func (l *LoadingCache) GetOrLoad(key string, ttl time.Duration, loader func() (interface{}, error)) (interface{}, error) {
+	val, err := l.Get(key)
+	if !errors.Is(err, ErrKeyNotFound) {
+		return val, err
+	}
+
+	l.mu.Lock()
+	if call, ok := l.calls[key]; ok {
+		l.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &loadCall{done: make(chan struct{})}
+	l.calls[key] = call
+	l.mu.Unlock()
+
+	l.load(call, key, ttl, loader)
+	return call.value, call.err
+}
+
+func (l *LoadingCache) load(call *loadCall, key string, ttl time.Duration, loader func() (interface{}, error)) {
+	finished := false
+	defer func() {
+		if !finished {
+			call.value, call.err = nil, errLoaderPanicked
+		}
+		l.mu.Lock()
+		delete(l.calls, key)
+		l.mu.Unlock()
+		close(call.done)
+	}()
+
+	// a previous leader may have stored the value between our miss and
+	// taking over the key
+	if val, err := l.Get(key); !errors.Is(err, ErrKeyNotFound) {
+		call.value, call.err = val, err
+		finished = true
+		return
+	}
+
+	call.value, call.err = loader()
+	finished = true
+	if call.err != nil {
+		return
+	}
+	if ttl > 0 {
+		call.err = l.SetWithTTL(key, call.value, ttl)
+	} else {
+		call.err = l.Set(key, call.value)
+	}
+}
@@ -0,0 +1,160 @@
+package cache_test
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/memory"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoadCachesResult(t *testing.T) {
+	lc := cache.NewLoadingCache(memory.NewMemorycache())
+	calls := 0
+	loader := func() (interface{}, error) {
+		calls++
+		return "loaded", nil
+	}
+	for i := 0; i < 3; i++ {
+		val, err := lc.GetOrLoad("k", time.Minute, loader)
+		if err != nil {
+			t.Fatalf("GetOrLoad failed: %v", err)
+		}
+		if val != "loaded" {
+			t.Errorf("Expected 'loaded', got %v", val)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected loader to run once, ran %d times", calls)
+	}
+}
+
+// concurrent misses for one key collapse into a single loader call
+func TestGetOrLoadDeduplicates(t *testing.T) {
+	lc := cache.NewLoadingCache(memory.NewMemorycache())
+	var calls int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+
+	const workers = 50
+	var wg sync.WaitGroup
+	results := make(chan interface{}, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := lc.GetOrLoad("hot", 0, loader)
+			if err != nil {
+				t.Errorf("GetOrLoad failed: %v", err)
+			}
+			results <- val
+		}()
+	}
+	// let the workers pile up behind the leader
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 loader call, got %d", n)
+	}
+	for val := range results {
+		if val != 42 {
+			t.Errorf("Expected 42, got %v", val)
+		}
+	}
+}
+
+// errors are shared with the waiters but not cached
+func TestGetOrLoadSharesErrors(t *testing.T) {
+	lc := cache.NewLoadingCache(memory.NewMemorycache())
+	boom := errors.New("boom")
+	_, err := lc.GetOrLoad("k", 0, func() (interface{}, error) {
+		return nil, boom
+	})
+	if err != boom {
+		t.Fatalf("Expected loader error, got %v", err)
+	}
+	if _, err := lc.Get("k"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected failed load not to be cached, got %v", err)
+	}
+
+	val, err := lc.GetOrLoad("k", 0, func() (interface{}, error) {
+		return "second", nil
+	})
+	if err != nil || val != "second" {
+		t.Errorf("Expected retry to load 'second', got %v (%v)", val, err)
+	}
+}
+
+func TestGetOrLoadPassesOtherErrors(t *testing.T) {
+	lc := cache.NewLoadingCache(memory.NewMemorycache())
+	_, err := lc.GetOrLoad("", 0, func() (interface{}, error) {
+		t.Error("loader must not run for an invalid key")
+		return nil, nil
+	})
+	if err != cache.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey, got %v", err)
+	}
+}
+
+func TestGetOrLoadPanicReleasesWaiters(t *testing.T) {
+	lc := cache.NewLoadingCache(memory.NewMemorycache())
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected loader panic to propagate")
+			}
+		}()
+		lc.GetOrLoad("k", 0, func() (interface{}, error) {
+			panic("loader bug")
+		})
+	}()
+
+	// the key must not stay locked by the dead call
+	val, err := lc.GetOrLoad("k", 0, func() (interface{}, error) {
+		return "ok", nil
+	})
+	if err != nil || val != "ok" {
+		t.Errorf("Expected 'ok' after panic, got %v (%v)", val, err)
+	}
+}
+
+// the waiters of a panicking loader get an error they can match
+func TestGetOrLoadPanicWaiters(t *testing.T) {
+	lc := cache.NewLoadingCache(memory.NewMemorycache())
+	release := make(chan struct{})
+	go func() {
+		defer func() { recover() }()
+		lc.GetOrLoad("k", 0, func() (interface{}, error) {
+			<-release
+			panic("loader bug")
+		})
+	}()
+	errLate := errors.New("joined after the panic")
+	done := make(chan error)
+	go func() {
+		// give the first call time to take the key
+		time.Sleep(10 * time.Millisecond)
+		_, err := lc.GetOrLoad("k", 0, func() (interface{}, error) {
+			return nil, errLate
+		})
+		done <- err
+	}()
+	time.Sleep(30 * time.Millisecond)
+	close(release)
+	err := <-done
+	if errors.Is(err, errLate) {
+		t.Skip("the waiter did not join the call in time")
+	}
+	if !errors.Is(err, cache.ErrPanic) {
+		t.Errorf("Expected ErrPanic for a waiter, got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Middleware wraps a Cache with extra behaviour, see Chain.
+type Middleware func(next Cache) Cache
+
+// Chain layers mws over base. the first middleware is the outermost one, so
+// Chain(c, a, b) runs a, then b, then c. the result is a plain Cache, the
+// optional interfaces of base (ContextCache, BatchCache, ...) are hidden
+// but can still be reached through Unwrap.
+func Chain(base Cache, mws ...Middleware) Cache {
+	c := base
+	for i := len(mws) - 1; i >= 0; i-- {
+		c = mws[i](c)
+	}
+	return c
+}
+
+// Funcs builds a Cache from functions, a nil function calls Next directly.
+// it saves middlewares from spelling out the methods they do not change.
+type Funcs struct {
+	Next           Cache
+	SetFunc        func(key string, value interface{}) error
+	SetWithTTLFunc func(key string, value interface{}, ttl time.Duration) error
+	GetFunc        func(key string) (interface{}, error)
+	DeleteFunc     func(key string) error
+	ClearFunc      func() error
+}
+
+var _ Cache = (*Funcs)(nil)
+
+// Unwrap returns Next, so Iterable, CapabilitiesOf and the like can reach
+// the optional interfaces of the wrapped cache. those bypass the functions,
+// middlewares that rewrite keys must not expose it.
+func (f *Funcs) Unwrap() Cache { return f.Next }
+
+func (f *Funcs) Set(key string, value interface{}) error {
+	if f.SetFunc != nil {
+		return f.SetFunc(key, value)
+	}
+	return f.Next.Set(key, value)
+}
+
+func (f *Funcs) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if f.SetWithTTLFunc != nil {
+		return f.SetWithTTLFunc(key, value, ttl)
+	}
+	return f.Next.SetWithTTL(key, value, ttl)
+}
+
+func (f *Funcs) Get(key string) (interface{}, error) {
+	if f.GetFunc != nil {
+		return f.GetFunc(key)
+	}
+	return f.Next.Get(key)
+}
+
+func (f *Funcs) Delete(key string) error {
+	if f.DeleteFunc != nil {
+		return f.DeleteFunc(key)
+	}
+	return f.Next.Delete(key)
+}
+
+func (f *Funcs) Clear() error {
+	if f.ClearFunc != nil {
+		return f.ClearFunc()
+	}
+	return f.Next.Clear()
+}
+
+// WithLogging logs every call with its key, duration and error at debug
+// level, failures other than a miss at error level. nil uses slog.Default().
+func WithLogging(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next Cache) Cache {
+		logCall := func(op, key string, start time.Time, err error) {
+			level := slog.LevelDebug
+			if err != nil && err != ErrKeyNotFound {
+				level = slog.LevelError
+			}
+			logger.Log(context.Background(), level, "cache "+op,
+				"key", key, "duration", time.Since(start), "error", err)
+		}
+		return &Funcs{
+			Next: next,
+			SetFunc: func(key string, value interface{}) error {
+				start := time.Now()
+				err := next.Set(key, value)
+				logCall("set", key, start, err)
+				return err
+			},
+			SetWithTTLFunc: func(key string, value interface{}, ttl time.Duration) error {
+				start := time.Now()
+				err := next.SetWithTTL(key, value, ttl)
+				logCall("set_with_ttl", key, start, err)
+				return err
+			},
+			GetFunc: func(key string) (interface{}, error) {
+				start := time.Now()
+				val, err := next.Get(key)
+				logCall("get", key, start, err)
+				return val, err
+			},
+			DeleteFunc: func(key string) error {
+				start := time.Now()
+				err := next.Delete(key)
+				logCall("delete", key, start, err)
+				return err
+			},
+			ClearFunc: func() error {
+				start := time.Now()
+				err := next.Clear()
+				logCall("clear", "", start, err)
+				return err
+			},
+		}
+	}
+}
+
+// WithPrefix puts prefix in front of every key. empty keys stay empty so
+// they are still rejected. Clear is passed on untouched and clears the
+// whole underlying cache, not only the prefixed keys. the result has no
+// Unwrap, the optional interfaces of next would bypass the prefix; its
+// Prefix method returns the prefix and next for callers that apply it.
+func WithPrefix(prefix string) Middleware {
+	return func(next Cache) Cache {
+		k := func(key string) string {
+			if key == "" {
+				return key
+			}
+			return prefix + key
+		}
+		return prefixed{prefix: prefix, next: next, Cache: &Funcs{
+			Next: next,
+			SetFunc: func(key string, value interface{}) error {
+				return next.Set(k(key), value)
+			},
+			SetWithTTLFunc: func(key string, value interface{}, ttl time.Duration) error {
+				return next.SetWithTTL(k(key), value, ttl)
+			},
+			GetFunc: func(key string) (interface{}, error) {
+				return next.Get(k(key))
+			},
+			DeleteFunc: func(key string) error {
+				return next.Delete(k(key))
+			},
+		}}
+	}
+}
+
+// prefixed hides everything of the Funcs it wraps but the Cache methods,
+// Unwrap included.
+type prefixed struct {
+	Cache
+	prefix string
+	next   Cache
+}
+
+// Prefix returns the prefix put in front of every key and the cache the
+// prefixed keys go to.
+func (p prefixed) Prefix() (string, Cache) {
+	return p.prefix, p.next
+}
+
+// WithMetrics counts hits, misses, sets and deletes into rec.
+func WithMetrics(rec *StatsRecorder) Middleware {
+	return func(next Cache) Cache {
+		return &Funcs{
+			Next: next,
+			SetFunc: func(key string, value interface{}) error {
+				err := next.Set(key, value)
+				if err == nil {
+					rec.AddSets(1)
+				}
+				return err
+			},
+			SetWithTTLFunc: func(key string, value interface{}, ttl time.Duration) error {
+				err := next.SetWithTTL(key, value, ttl)
+				if err == nil {
+					rec.AddSets(1)
+				}
+				return err
+			},
+			GetFunc: func(key string) (interface{}, error) {
+				val, err := next.Get(key)
+				switch err {
+				case nil:
+					rec.AddHits(1)
+				case ErrKeyNotFound:
+					rec.AddMisses(1)
+				}
+				return val, err
+			},
+			DeleteFunc: func(key string) error {
+				err := next.Delete(key)
+				if err == nil {
+					rec.AddDeletes(1)
+				}
+				return err
+			},
+		}
+	}
+}
+
+// WithRecovery turns a panic below it into an error wrapping ErrPanic.
+func WithRecovery() Middleware {
+	return func(next Cache) Cache {
+		return &Funcs{
+			Next: next,
+			SetFunc: func(key string, value interface{}) (err error) {
+				defer recoverInto(&err)
+				return next.Set(key, value)
+			},
+			SetWithTTLFunc: func(key string, value interface{}, ttl time.Duration) (err error) {
+				defer recoverInto(&err)
+				return next.SetWithTTL(key, value, ttl)
+			},
+			GetFunc: func(key string) (val interface{}, err error) {
+				defer recoverInto(&err)
+				return next.Get(key)
+			},
+			DeleteFunc: func(key string) (err error) {
+				defer recoverInto(&err)
+				return next.Delete(key)
+			},
+			ClearFunc: func() (err error) {
+				defer recoverInto(&err)
+				return next.Clear()
+			},
+		}
+	}
+}
+
+func recoverInto(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w: %v", ErrPanic, r)
+	}
+}
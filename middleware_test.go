@@ -0,0 +1,139 @@
+package cache_test
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/memory"
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// records the order middlewares see a call in
+func tag(name string, seen *[]string) cache.Middleware {
+	return func(next cache.Cache) cache.Cache {
+		return &cache.Funcs{
+			Next: next,
+			GetFunc: func(key string) (interface{}, error) {
+				*seen = append(*seen, name)
+				return next.Get(key)
+			},
+		}
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var seen []string
+	c := cache.Chain(memory.NewMemorycache(), tag("a", &seen), tag("b", &seen), tag("c", &seen))
+	c.Get("k")
+	if got := strings.Join(seen, ","); got != "a,b,c" {
+		t.Errorf("Expected a,b,c, got %s", got)
+	}
+}
+
+func TestChainNoMiddleware(t *testing.T) {
+	base := memory.NewMemorycache()
+	if c := cache.Chain(base); c != cache.Cache(base) {
+		t.Error("Expected Chain without middlewares to return base")
+	}
+}
+
+func TestChainUnwrap(t *testing.T) {
+	base := memory.NewMemorycache()
+	c := cache.Chain(base, cache.WithRecovery(), cache.WithLogging(nil))
+	u, ok := c.(interface{ Unwrap() cache.Cache })
+	if !ok {
+		t.Fatal("Expected Funcs to have Unwrap")
+	}
+	if u.Unwrap() == nil || u.Unwrap() == c {
+		t.Errorf("Expected the next cache from Unwrap, got %v", u.Unwrap())
+	}
+	// the optional interfaces of base are reachable through the chain
+	base.Set("k", "v")
+	ic, err := cache.Iterable(c)
+	if err != nil || ic != cache.IterableCache(base) {
+		t.Errorf("Expected base from Iterable, got %v, %v", ic, err)
+	}
+	if caps := cache.CapabilitiesOf(c); !caps.AnyValue || !caps.Iteration {
+		t.Errorf("Expected base's capabilities through the chain, got %+v", caps)
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+	base := memory.NewMemorycache()
+	c := cache.Chain(base, cache.WithPrefix("app:"))
+	if err := c.SetWithTTL("k", "v", time.Minute); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	if val, err := base.Get("app:k"); err != nil || val != "v" {
+		t.Errorf("Expected app:k=v in base, got %v, %v", val, err)
+	}
+	if val, err := c.Get("k"); err != nil || val != "v" {
+		t.Errorf("Expected k=v through prefix, got %v, %v", val, err)
+	}
+	if err := c.Delete("k"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := base.Get("app:k"); err != cache.ErrKeyNotFound {
+		t.Errorf("Expected app:k deleted, got %v", err)
+	}
+	if err := c.Set("", "v"); err != cache.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey, got %v", err)
+	}
+
+	// the base cache must not be reached around the prefix
+	if _, ok := c.(interface{ Unwrap() cache.Cache }); ok {
+		t.Error("Expected no Unwrap behind WithPrefix")
+	}
+	if _, err := cache.Iterable(cache.Chain(base, cache.WithRecovery(), cache.WithPrefix("app:"))); err != cache.ErrNotSupported {
+		t.Errorf("Expected ErrNotSupported from Iterable, got %v", err)
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	var rec cache.StatsRecorder
+	c := cache.Chain(memory.NewMemorycache(), cache.WithMetrics(&rec))
+	c.Set("a", 1)
+	c.Get("a")
+	c.Get("missing")
+	c.Delete("a")
+	s := rec.Snapshot()
+	if s.Sets != 1 || s.Hits != 1 || s.Misses != 1 || s.Deletes != 1 {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+}
+
+type panicky struct{ cache.Cache }
+
+func (panicky) Get(string) (interface{}, error) { panic("boom") }
+
+func TestWithRecovery(t *testing.T) {
+	c := cache.Chain(panicky{memory.NewMemorycache()}, cache.WithRecovery())
+	_, err := c.Get("k")
+	if !errors.Is(err, cache.ErrPanic) {
+		t.Fatalf("Expected ErrPanic, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected panic value in error, got %v", err)
+	}
+	if err := c.Set("k", "v"); err != nil {
+		t.Errorf("Expected Set to pass through, got %v", err)
+	}
+}
+
+func TestWithLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := cache.Chain(memory.NewMemorycache(), cache.WithLogging(logger))
+	c.Set("user:1", "v")
+	c.Get("user:1")
+	out := buf.String()
+	if !strings.Contains(out, "cache set") || !strings.Contains(out, "cache get") {
+		t.Errorf("Expected set and get logged, got %q", out)
+	}
+	if !strings.Contains(out, "key=user:1") {
+		t.Errorf("Expected key in log, got %q", out)
+	}
+}
@@ -0,0 +1,63 @@
+package cache
+
+import "sync/atomic"
+
+// Stats is a snapshot of a cache's counters.
+type Stats struct {
+	Hits        int64
+	Misses      int64
+	Sets        int64
+	Deletes     int64
+	Evictions   int64 // dropped to make room
+	Expirations int64 // dropped because their TTL ran out
+	// Items and Bytes describe what the backend currently holds, -1 when
+	// the backend cannot tell. remote servers report them server wide.
+	Items int64
+	Bytes int64
+}
+
+// HitRatio is Hits / (Hits + Misses), 0 before the first read.
+func (s Stats) HitRatio() float64 {
+	reads := s.Hits + s.Misses
+	if reads == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(reads)
+}
+
+// StatsProvider is implemented by caches that keep Stats.
+type StatsProvider interface {
+	Stats() Stats
+}
+
+// StatsRecorder counts cache operations, safe for concurrent use.
+// the zero value is ready to use.
+type StatsRecorder struct {
+	hits        atomic.Int64
+	misses      atomic.Int64
+	sets        atomic.Int64
+	deletes     atomic.Int64
+	evictions   atomic.Int64
+	expirations atomic.Int64
+}
+
+func (r *StatsRecorder) AddHits(n int64)        { r.hits.Add(n) }
+func (r *StatsRecorder) AddMisses(n int64)      { r.misses.Add(n) }
+func (r *StatsRecorder) AddSets(n int64)        { r.sets.Add(n) }
+func (r *StatsRecorder) AddDeletes(n int64)     { r.deletes.Add(n) }
+func (r *StatsRecorder) AddEvictions(n int64)   { r.evictions.Add(n) }
+func (r *StatsRecorder) AddExpirations(n int64) { r.expirations.Add(n) }
+
+// Snapshot returns the counters, Items and Bytes are left unknown (-1).
+func (r *StatsRecorder) Snapshot() Stats {
+	return Stats{
+		Hits:        r.hits.Load(),
+		Misses:      r.misses.Load(),
+		Sets:        r.sets.Load(),
+		Deletes:     r.deletes.Load(),
+		Evictions:   r.evictions.Load(),
+		Expirations: r.expirations.Load(),
+		Items:       -1,
+		Bytes:       -1,
+	}
+}
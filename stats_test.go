@@ -0,0 +1,27 @@
+package cache_test
+
+import (
+	"Go-library/cache"
+	"testing"
+)
+
+func TestStatsRecorder(t *testing.T) {
+	var r cache.StatsRecorder
+	r.AddHits(3)
+	r.AddMisses(1)
+	r.AddSets(2)
+	r.AddDeletes(1)
+	r.AddEvictions(4)
+	r.AddExpirations(5)
+
+	want := cache.Stats{Hits: 3, Misses: 1, Sets: 2, Deletes: 1, Evictions: 4, Expirations: 5, Items: -1, Bytes: -1}
+	if got := r.Snapshot(); got != want {
+		t.Fatalf("Expected %+v, got %+v", want, got)
+	}
+	if ratio := want.HitRatio(); ratio != 0.75 {
+		t.Errorf("Expected hit ratio 0.75, got %f", ratio)
+	}
+	if ratio := (cache.Stats{}).HitRatio(); ratio != 0 {
+		t.Errorf("Expected hit ratio 0 without reads, got %f", ratio)
+	}
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TypedCache is a type-safe view over any Cache.
+// values are encoded to a JSON string before they reach the backend, so a T
+// comes back as the same T from memory, redis and memcached alike (redis on
+// its own hands back map[string]interface{} for structs, memcached only takes
+// strings).
+type TypedCache[T any] struct {
+	c Cache
+}
+
+// NewTyped wraps c so it stores and returns values of type T.
+func NewTyped[T any](c Cache) *TypedCache[T] {
+	return &TypedCache[T]{c: c}
+}
+
+// Set adds or updates a value.
+func (t *TypedCache[T]) Set(key string, value T) error {
+	return t.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL adds or updates a value with a time to live.
+func (t *TypedCache[T]) SetWithTTL(key string, value T, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if ttl == 0 {
+		return t.c.Set(key, string(data))
+	}
+	return t.c.SetWithTTL(key, string(data), ttl)
+}
+
+// Get retrieves a value. a stored value that cannot be turned back into a T
+// returns an error wrapping ErrDecode.
+func (t *TypedCache[T]) Get(key string) (T, error) {
+	var out T
+	raw, err := t.c.Get(key)
+	if err != nil {
+		return out, err
+	}
+	var data []byte
+	switch v := raw.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		// written straight to an in-process backend, not through TypedCache
+		if tv, ok := raw.(T); ok {
+			return tv, nil
+		}
+		return out, fmt.Errorf("%w: unexpected stored type %T", ErrDecode, raw)
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+	return out, nil
+}
+
+// Delete removes a key.
+func (t *TypedCache[T]) Delete(key string) error {
+	return t.c.Delete(key)
+}
+
+// Clear removes all keys of the underlying cache.
+func (t *TypedCache[T]) Clear() error {
+	return t.c.Clear()
+}
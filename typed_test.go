@@ -0,0 +1,99 @@
+package cache_test
+
+import (
+	"Go-library/cache"
+	"Go-library/cache/cache/memory"
+	"Go-library/cache/cache/redis"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+type profile struct {
+	Name  string
+	Age   int
+	Tags  []string
+	Score float64
+}
+
+func newTypedBackends(t *testing.T) map[string]cache.Cache {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	rc, err := redis.NewRedisCache(redis.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	return map[string]cache.Cache{
+		"memory": memory.NewMemorycache(),
+		"redis":  rc,
+	}
+}
+
+// the same struct must come back identical from every backend
+func TestTypedRoundTrip(t *testing.T) {
+	for name, backend := range newTypedBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			tc := cache.NewTyped[profile](backend)
+			want := profile{Name: "ana", Age: 31, Tags: []string{"a", "b"}, Score: 9.5}
+			if err := tc.Set("p1", want); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			got, err := tc.Get("p1")
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+
+			_, err = tc.Get("missing")
+			if err != cache.ErrKeyNotFound {
+				t.Errorf("Expected ErrKeyNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTypedPrimitives(t *testing.T) {
+	for name, backend := range newTypedBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ints := cache.NewTyped[int64](backend)
+			if err := ints.Set("n", 1<<40); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			n, err := ints.Get("n")
+			if err != nil || n != 1<<40 {
+				t.Errorf("Expected %d, got %d (%v)", int64(1<<40), n, err)
+			}
+
+			strs := cache.NewTyped[string](backend)
+			if err := strs.Set("s", "hello"); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			s, err := strs.Get("s")
+			if err != nil || s != "hello" {
+				t.Errorf("Expected 'hello', got %q (%v)", s, err)
+			}
+		})
+	}
+}
+
+func TestTypedDecodeError(t *testing.T) {
+	backend := memory.NewMemorycache()
+	// written outside the typed view with an incompatible shape
+	backend.Set("bad", "not json")
+	backend.Set("other", 42)
+
+	tc := cache.NewTyped[profile](backend)
+	if _, err := tc.Get("bad"); !errors.Is(err, cache.ErrDecode) {
+		t.Errorf("Expected ErrDecode, got %v", err)
+	}
+	if _, err := tc.Get("other"); !errors.Is(err, cache.ErrDecode) {
+		t.Errorf("Expected ErrDecode for foreign type, got %v", err)
+	}
+}